@@ -6,17 +6,29 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"strconv"
 
 	"github.com/nevivurn/npass/pkg/pinentry"
 )
 
-const envDBKey = "NPASS_DB"
+const (
+	envDBKey            = "NPASS_DB"
+	envMaxFileSizeKey   = "NPASS_MAX_FILE_SIZE"
+	envHistoryKeepKey   = "NPASS_HISTORY_KEEP"
+	envHistoryMaxAgeKey = "NPASS_HISTORY_MAX_AGE"
+)
 
 type app struct {
 	r   io.Reader
 	w   io.Writer
 	st  store
 	pin pinentry.Pinentry
+
+	// Size limit of file passes, or the default if zero.
+	maxFileSize int64
+	// Number of previous versions kept per pass, and their maximum age in
+	// days. Zero means unlimited.
+	historyKeep, historyMaxAge int64
 }
 
 func newApp(ctx context.Context) (*app, error) {
@@ -25,6 +37,25 @@ func newApp(ctx context.Context) (*app, error) {
 		w: os.Stdout,
 	}
 
+	for _, env := range []struct {
+		key string
+		v   *int64
+	}{
+		{envMaxFileSizeKey, &a.maxFileSize},
+		{envHistoryKeepKey, &a.historyKeep},
+		{envHistoryMaxAgeKey, &a.historyMaxAge},
+	} {
+		s := os.Getenv(env.key)
+		if s == "" {
+			continue
+		}
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid %s: %q", env.key, s)
+		}
+		*env.v = n
+	}
+
 	db := os.Getenv(envDBKey)
 	if db == "" {
 		db = filepath.Join(os.Getenv("HOME"), ".npass.db")
@@ -36,17 +67,25 @@ func newApp(ctx context.Context) (*app, error) {
 	}
 	a.st = st
 
-	ok, err := st.checkSchema(ctx)
+	version, err := st.version(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("could not open db: %w", err)
 	}
 
-	if !ok {
+	switch {
+	case version == 0:
 		err := st.initSchema(ctx)
 		if err != nil {
 			return nil, fmt.Errorf("could not initialize db: %w", err)
 		}
 		fmt.Fprintf(a.w, "Initialized new db at %s\n", db)
+	case version != schemaVersion():
+		backup := fmt.Sprintf("%s.v%d.bak", db, version)
+		if _, err := st.migrate(ctx, backup); err != nil {
+			return nil, fmt.Errorf("could not open db: %w", err)
+		}
+		fmt.Fprintf(a.w, "Migrated db at %s from version %d to %d, backup saved at %s\n",
+			db, version, schemaVersion(), backup)
 	}
 
 	a.pin = pinentry.External
@@ -58,9 +97,43 @@ func (a *app) Close() error {
 	return a.st.Close()
 }
 
+// openInput replaces a.r with the named file until the returned function is
+// called. An empty name leaves a.r unchanged.
+func (a *app) openInput(name string) (func(), error) {
+	if name == "" {
+		return func() {}, nil
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	r := a.r
+	a.r = f
+	return func() {
+		a.r = r
+		f.Close()
+	}, nil
+}
+
 func (a *app) run(ctx context.Context, args []string) error {
 	return runMap{
-		"new":  runFunc(a.cmdNew),
-		"show": runFunc(a.cmdShow),
+		"edit":        runFunc(a.cmdEdit),
+		"empty-trash": runFunc(a.cmdEmptyTrash),
+		"exec":        runFunc(a.cmdExec),
+		"find":        runFunc(a.cmdFind),
+		"history":     runFunc(a.cmdHistory),
+		"mv":          runFunc(a.cmdMv),
+		"new":         runFunc(a.cmdNew),
+		"passwd":      runFunc(a.cmdPasswd),
+		"restore":     runFunc(a.cmdRestore),
+		"rm":          runFunc(a.cmdRm),
+		"rotate":      runFunc(a.cmdRotate),
+		"show":        runFunc(a.cmdShow),
+		"ssh-agent":   runFunc(a.cmdSSHAgent),
+		"tag":         runFunc(a.cmdTag),
+		"trash":       runFunc(a.cmdTrash),
+		"upgrade":     runFunc(a.cmdUpgrade),
 	}.run(ctx, args)
 }
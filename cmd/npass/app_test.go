@@ -3,11 +3,14 @@ package main
 import (
 	"bytes"
 	"context"
+	"database/sql"
 	"errors"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/nevivurn/npass/pkg/pinentry"
@@ -118,9 +121,87 @@ func TestNewAppDefault(t *testing.T) {
 	}
 }
 
+func TestNewAppMigrate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "npass-test-")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	db := filepath.Join(dir, "npass.db")
+
+	st, err := newStore(db, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	testStoreFixture(t, st)
+	st.Close()
+
+	oldEnv := os.Getenv(envDBKey)
+	os.Setenv(envDBKey, db)
+	defer func() { os.Setenv(envDBKey, oldEnv) }()
+
+	oldStdout := os.Stdout
+	tmpOut, err := ioutil.TempFile(dir, "out-*")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer tmpOut.Close()
+	os.Stdout = tmpOut
+	defer func() { os.Stdout = oldStdout }()
+
+	// The fixture is at the initial version
+	from := 1
+	testMigrations(t, func(ctx context.Context, tx *sql.Tx) error {
+		_, err := tx.Exec(`CREATE TABLE test (id INTEGER PRIMARY KEY)`)
+		return err
+	})
+
+	a, err := newApp(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	a.Close()
+
+	backup := fmt.Sprintf("%s.v%d.bak", db, from)
+	if _, err := os.Stat(backup); err != nil {
+		t.Errorf("backup not created: %v", err)
+	}
+
+	out, err := ioutil.ReadFile(tmpOut.Name())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := fmt.Sprintf("Migrated db at %s from version %d to %d, backup saved at %s\n",
+		db, from, schemaVersion(), backup)
+	if string(out) != want {
+		t.Errorf("output = %q; want %q", string(out), want)
+	}
+
+	// The database is now too new for the binary
+	migrations = migrations[:len(migrations)-1]
+	_, err = newApp(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "newer than supported") {
+		t.Errorf("newApp() err = %v; want newer version error", err)
+	}
+}
+
+func TestNewAppMaxFileSize(t *testing.T) {
+	oldEnv := os.Getenv(envMaxFileSizeKey)
+	defer func() { os.Setenv(envMaxFileSizeKey, oldEnv) }()
+
+	for _, s := range []string{"invalid", "0", "-1"} {
+		os.Setenv(envMaxFileSizeKey, s)
+		_, err := newApp(context.Background())
+		if err == nil {
+			t.Errorf("newApp() with %s=%q did not error", envMaxFileSizeKey, s)
+		}
+	}
+}
+
 type testPinentry struct {
 	confirm bool
 	pass    string
+	newPass string // returned by NewPass instead of pass, if set
 	err     error
 }
 
@@ -130,6 +211,9 @@ func (tp testPinentry) Confirm(context.Context, string) (bool, error) {
 	return tp.confirm, tp.err
 }
 func (tp testPinentry) NewPass(context.Context, string) (string, error) {
+	if tp.newPass != "" {
+		return tp.newPass, tp.err
+	}
 	return tp.pass, tp.err
 }
 func (tp testPinentry) AskPass(_ context.Context, _ string, f func(string) bool) (string, error) {
@@ -143,18 +227,13 @@ func (tp testPinentry) AskPass(_ context.Context, _ string, f func(string) bool)
 }
 
 func testNewApp(t *testing.T, pin pinentry.Pinentry) (*app, *bytes.Buffer) {
-	fastKDF = true
-	t.Cleanup(func() { fastKDF = false })
+	testKDF(t)
 
 	buf := &bytes.Buffer{}
 
-	schema, err := ioutil.ReadFile("testdata/test_schema.sql")
-	if err != nil {
-		t.Fatalf("unexpected error: %v", err)
-	}
-
 	st := testStore(t)
-	if _, err := st.Exec(string(schema)); err != nil {
+	testStoreFixture(t, st)
+	if _, err := st.migrate(context.Background(), ""); err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
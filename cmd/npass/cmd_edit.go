@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+func (a *app) cmdEdit(ctx context.Context, args []string) error {
+	fs := newFlagSet("edit")
+	in := fs.String("in", "", "read the pass from this file instead of stdin")
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	args = fs.Args()
+
+	if len(args) != 1 {
+		return errUsage
+	}
+
+	key, name, typ, err := parseIdentifier(args[0])
+	if err != nil {
+		return err
+	}
+	if key == "" || name == "" || typ == "" {
+		return errUsage
+	}
+	fullName := fmt.Sprintf("%s:%s:%s", key, name, typ)
+
+	done, err := a.openInput(*in)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	tx, err := a.st.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	k, err := queryKey(tx, key)
+	if err != nil {
+		return err
+	}
+
+	if err := a.openIndex(ctx, &k); err != nil {
+		return err
+	}
+	p, err := queryPass(tx, k, name, typ)
+	if err != nil {
+		return err
+	}
+
+	pass, err := a.unlockPass(ctx, tx, k, p)
+	if err != nil {
+		return err
+	}
+
+	if err := pass.readPass(ctx, a, name); err != nil {
+		return err
+	}
+
+	if err := a.recordHistory(tx, k, p); err != nil {
+		return err
+	}
+	if err := updatePass(tx, k, p, pass); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(a.w, "updated pass %q\n", fullName)
+	return tx.Commit()
+}
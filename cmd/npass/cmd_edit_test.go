@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestCmdEdit(t *testing.T) {
+	ctx := context.Background()
+	app, _ := testNewApp(t, &testPinentry{})
+
+	err := app.run(ctx, []string{"edit"})
+	if !errors.Is(err, errUsage) {
+		t.Errorf("edit err = %v; want %v", err, errUsage)
+	}
+
+	err = app.run(ctx, []string{"edit", "test-1:test-1"})
+	if !errors.Is(err, errUsage) {
+		t.Errorf("edit err = %v; want %v", err, errUsage)
+	}
+
+	err = app.run(ctx, []string{"edit", "INVALID"})
+	if !errors.Is(err, errIdentifier) {
+		t.Errorf("edit err = %v; want %v", err, errIdentifier)
+	}
+}
+
+func TestCmdEditPass(t *testing.T) {
+	ctx := context.Background()
+	pin := &testPinentry{pass: "pass-1", newPass: "pass-edited"}
+	app, out := testNewApp(t, pin)
+
+	err := app.run(ctx, []string{"edit", "test-1:test-1:pass"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if want := fmt.Sprintf("updated pass %q\n", "test-1:test-1:pass"); out.String() != want {
+		t.Errorf("edit out = %q; want %q", out.String(), want)
+	}
+
+	var id int64
+	err = app.st.QueryRow(`SELECT id FROM pass WHERE key_id = 1 AND name = 'test-1' AND type = 'pass'`).Scan(&id)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if id != 1 {
+		t.Errorf("pass id = %d; want %d", id, 1)
+	}
+
+	out.Reset()
+	err = app.run(ctx, []string{"show", "test-1:test-1:pass"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "pass-edited\n"; out.String() != want {
+		t.Errorf("show (pass) out = %q; want %q", out.String(), want)
+	}
+}
+
+func TestCmdEditKeyFail(t *testing.T) {
+	ctx := context.Background()
+	app, _ := testNewApp(t, &testPinentry{})
+
+	err := app.run(ctx, []string{"edit", "test-none:test-1:pass"})
+	if want := fmt.Errorf("non-existent key %q", "test-none"); !reflect.DeepEqual(err, want) {
+		t.Errorf("edit err = %v; want %v", err, want)
+	}
+}
+
+func TestCmdEditPassFail(t *testing.T) {
+	ctx := context.Background()
+	app, _ := testNewApp(t, &testPinentry{})
+
+	err := app.run(ctx, []string{"edit", "test-1:none:pass"})
+	if want := fmt.Errorf("non-existent pass %q", "test-1:none:pass"); !reflect.DeepEqual(err, want) {
+		t.Errorf("edit err = %v; want %v", err, want)
+	}
+}
+
+func TestCmdEditPinFail(t *testing.T) {
+	ctx := context.Background()
+	pin := &testPinentry{pass: "incorrect"}
+	app, _ := testNewApp(t, pin)
+
+	err := app.run(ctx, []string{"edit", "test-1:test-1:pass"})
+	if !errors.Is(err, errTestPinentryVerify) {
+		t.Errorf("edit err = %v; want %v", err, errTestPinentryVerify)
+	}
+}
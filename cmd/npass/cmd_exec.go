@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+)
+
+const envType = "env"
+
+// Signals forwarded to the child of exec.
+var forwardSignals = []os.Signal{os.Interrupt, syscall.SIGTERM, syscall.SIGHUP}
+
+// exitError reports the exit status of a child process, to be used as the
+// exit status of npass itself.
+type exitError struct {
+	code int
+}
+
+func (e exitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.code)
+}
+
+func (a *app) cmdExec(ctx context.Context, args []string) error {
+	if len(args) < 2 {
+		return errUsage
+	}
+
+	key, name, typ, err := parseIdentifier(args[0])
+	if err != nil {
+		return err
+	}
+	if key == "" || name == "" || typ != envType {
+		return errUsage
+	}
+
+	args = args[1:]
+	if args[0] == "--" {
+		args = args[1:]
+	}
+	if len(args) == 0 {
+		return errUsage
+	}
+
+	env, err := a.loadEnv(ctx, key, name)
+	if err != nil {
+		return err
+	}
+
+	path, err := exec.LookPath(args[0])
+	if err != nil {
+		return err
+	}
+
+	// The secrets are only ever passed to the child, never written out.
+	cmd := &exec.Cmd{
+		Path:   path,
+		Args:   args,
+		Env:    append(os.Environ(), env.environ()...),
+		Stdin:  a.r,
+		Stdout: a.w,
+		Stderr: os.Stderr,
+	}
+
+	// Forward signals until the child exits, and leave it to the child to
+	// exit. They are caught before starting it, so that npass never exits
+	// first, leaving the child behind.
+	sigs := make(chan os.Signal, 8)
+	signal.Notify(sigs, forwardSignals...)
+	defer signal.Stop(sigs)
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	exited := make(chan struct{})
+	defer close(exited)
+	go func() {
+		var forwarded bool
+		done := ctx.Done()
+		for {
+			select {
+			case sig := <-sigs:
+				_ = cmd.Process.Signal(sig)
+				forwarded = true
+			case <-done:
+				done = nil
+				// Interrupts also cancel ctx, and were forwarded already
+				for len(sigs) > 0 {
+					_ = cmd.Process.Signal(<-sigs)
+					forwarded = true
+				}
+				if !forwarded {
+					_ = cmd.Process.Signal(os.Interrupt)
+				}
+			case <-exited:
+				return
+			}
+		}
+	}()
+
+	err = cmd.Wait()
+	var ee *exec.ExitError
+	if errors.As(err, &ee) {
+		code := ee.ExitCode()
+		if ws, ok := ee.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+			code = 128 + int(ws.Signal())
+		}
+		return exitError{code}
+	}
+	return err
+}
+
+// loadEnv decrypts the env pass key:name:env.
+func (a *app) loadEnv(ctx context.Context, key, name string) (*passEnv, error) {
+	tx, err := a.st.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	k, err := queryKey(tx, key)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.openIndex(ctx, &k); err != nil {
+		return nil, err
+	}
+	p, err := queryPass(tx, k, name, envType)
+	if err != nil {
+		return nil, err
+	}
+
+	pass, err := a.unlockPass(ctx, tx, k, p)
+	if err != nil {
+		return nil, err
+	}
+	if err := accessPass(tx, k, p); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return pass.(*passEnv), nil
+}
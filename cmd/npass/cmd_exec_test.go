@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func testExecApp(t *testing.T) (*app, *testPinentry) {
+	pin := &testPinentry{pass: "pass-1"}
+	app, _ := testNewApp(t, pin)
+
+	app.r = strings.NewReader("NPASS_TEST_FOO=foo bar\nNPASS_TEST_BAR='baz'\n")
+	err := app.run(context.Background(), []string{"new", "test-1:service:env"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	app.r = strings.NewReader("")
+
+	return app, pin
+}
+
+func TestCmdExec(t *testing.T) {
+	app, _ := testExecApp(t)
+	out := &strings.Builder{}
+	app.w = out
+
+	err := app.run(context.Background(), []string{"exec", "test-1:service:env", "--",
+		"sh", "-c", `printf '%s|%s' "$NPASS_TEST_FOO" "$NPASS_TEST_BAR"`})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "foo bar|'baz'"; out.String() != want {
+		t.Errorf("exec out = %q; want %q", out.String(), want)
+	}
+}
+
+func TestCmdExecStatus(t *testing.T) {
+	app, _ := testExecApp(t)
+
+	err := app.run(context.Background(), []string{"exec", "test-1:service:env", "sh", "-c", "exit 3"})
+	if want := (exitError{3}); !reflect.DeepEqual(err, want) {
+		t.Errorf("exec err = %v; want %v", err, want)
+	}
+}
+
+func TestCmdExecInterrupt(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	app, _ := testExecApp(t)
+	r, w := io.Pipe()
+	app.w = w
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- app.run(ctx, []string{"exec", "test-1:service:env", "--",
+			"sh", "-c", "trap 'exit 7' INT; echo ready; while :; do sleep 0.1; done"})
+		w.Close()
+	}()
+
+	if _, err := bufio.NewReader(r).ReadString('\n'); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cancel()
+
+	if err, want := <-errc, (exitError{7}); !reflect.DeepEqual(err, want) {
+		t.Errorf("exec err = %v; want %v", err, want)
+	}
+}
+
+func TestCmdExecSignals(t *testing.T) {
+	app, _ := testExecApp(t)
+	r, w := io.Pipe()
+	app.w = w
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- app.run(context.Background(), []string{"exec", "test-1:service:env", "--",
+			"sh", "-c", "trap 'echo hup' HUP; trap 'exit 9' TERM; echo ready; while :; do sleep 0.1; done"})
+		w.Close()
+	}()
+
+	self, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	br := bufio.NewReader(r)
+	if _, err := br.ReadString('\n'); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// Every signal is forwarded, not only the first
+	for i := 0; i < 2; i++ {
+		if err := self.Signal(syscall.SIGHUP); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if line, err := br.ReadString('\n'); err != nil || line != "hup\n" {
+			t.Fatalf("exec out = %q, %v; want %q", line, err, "hup\n")
+		}
+	}
+	if err := self.Signal(syscall.SIGTERM); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err, want := <-errc, (exitError{9}); !reflect.DeepEqual(err, want) {
+		t.Errorf("exec err = %v; want %v", err, want)
+	}
+}
+
+func TestCmdExecFail(t *testing.T) {
+	ctx := context.Background()
+	app, pin := testExecApp(t)
+
+	tests := []struct {
+		args []string
+		err  error
+	}{
+		{[]string{"exec"}, errUsage},
+		{[]string{"exec", "test-1:service:env"}, errUsage},
+		{[]string{"exec", "test-1:service:env", "--"}, errUsage},
+		{[]string{"exec", "test-1:service", "true"}, errUsage},
+		{[]string{"exec", "test-1:test-1:pass", "true"}, errUsage},
+	}
+
+	for _, tt := range tests {
+		err := app.run(ctx, tt.args)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%v err = %v; want %v", tt.args, err, tt.err)
+		}
+	}
+
+	pin.pass = "incorrect"
+	err := app.run(ctx, []string{"exec", "test-1:service:env", "true"})
+	if !errors.Is(err, errTestPinentryVerify) {
+		t.Errorf("exec err = %v; want %v", err, errTestPinentryVerify)
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+func (a *app) cmdFind(ctx context.Context, args []string) error {
+	fs := newFlagSet("find")
+	glob := fs.Bool("glob", false, "match names against a glob pattern")
+	regex := fs.Bool("regex", false, "match names against a regular expression")
+	tags := fs.String("tag", "", "only find passes with all of these comma-separated tags")
+	typ := fs.String("type", "", "only find passes of this type")
+	key := fs.String("key", "", "only find passes under this key")
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	args = fs.Args()
+
+	if len(args) > 1 || (*glob && *regex) {
+		return errUsage
+	}
+	var pattern string
+	if len(args) == 1 {
+		pattern = args[0]
+	}
+
+	var (
+		conds     []string
+		condsArgs []interface{}
+	)
+	if *key != "" {
+		conds = append(conds, `keys.name = ?`)
+		condsArgs = append(condsArgs, *key)
+	}
+	if *typ != "" {
+		conds = append(conds, `pass.type = ?`)
+		condsArgs = append(condsArgs, *typ)
+	}
+	if *tags != "" {
+		for _, tag := range strings.Split(*tags, ",") {
+			conds = append(conds, `EXISTS(SELECT 1 FROM tags WHERE tags.pass_id = pass.id AND tags.tag = ?)`)
+			condsArgs = append(condsArgs, tag)
+		}
+	}
+
+	// Regular expressions are matched below, the rest by the database.
+	var re *regexp.Regexp
+	switch {
+	case pattern == "":
+	case *regex:
+		var err error
+		re, err = regexp.Compile(pattern)
+		if err != nil {
+			return err
+		}
+	case *glob:
+		conds = append(conds, `pass.name GLOB ?`)
+		condsArgs = append(condsArgs, pattern)
+	default:
+		conds = append(conds, `instr(lower(pass.name), lower(?)) > 0`)
+		condsArgs = append(condsArgs, pattern)
+	}
+
+	where := strings.Join(append(conds, `pass.trashed IS NULL`, `keys.trashed IS NULL`), " AND ")
+
+	tx, err := a.st.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// Passes under a private index are matched below, once decrypted
+	queryPass := `SELECT keys.name, pass.name, pass.type FROM pass JOIN keys ON pass.key_id = keys.id WHERE ` +
+		where + ` AND keys.private_index = 0`
+	rows, err := tx.Query(queryPass, condsArgs...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	type foundPass struct{ key, name, typ string }
+
+	var found []foundPass
+	for rows.Next() {
+		var f foundPass
+		if err := rows.Scan(&f.key, &f.name, &f.typ); err != nil {
+			return err
+		}
+		if re != nil && !re.MatchString(f.name) {
+			continue
+		}
+		found = append(found, f)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	// Passes under a private index have no tags
+	if *tags == "" {
+		queryKeys := `SELECT id, name, public, private, private_index FROM keys
+		WHERE private_index != 0 AND trashed IS NULL AND (? = '' OR name = ?) ORDER BY name`
+		rows, err := tx.Query(queryKeys, *key, *key)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		var keys []dbKey
+		for rows.Next() {
+			var k dbKey
+			if err := rows.Scan(&k.id, &k.name, &k.pub, &k.priv, &k.privateIndex); err != nil {
+				return err
+			}
+			keys = append(keys, k)
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		rows.Close()
+
+		for _, k := range keys {
+			pass, err := queryPasses(tx, k, `trashed IS NULL`)
+			if err != nil {
+				return err
+			}
+			if len(pass) == 0 {
+				continue
+			}
+			if err := a.openIndex(ctx, &k); err != nil {
+				return err
+			}
+			if err := namePasses(k, pass); err != nil {
+				return err
+			}
+
+			for _, p := range pass {
+				if *typ != "" && p.typ != *typ {
+					continue
+				}
+				if ok, err := matchName(tx, p.name, pattern, *glob, re); err != nil {
+					return err
+				} else if !ok {
+					continue
+				}
+				found = append(found, foundPass{k.name, p.name, p.typ})
+			}
+		}
+	}
+
+	sort.Slice(found, func(i, j int) bool {
+		p, q := found[i], found[j]
+		if p.key != q.key {
+			return p.key < q.key
+		}
+		if p.name != q.name {
+			return p.name < q.name
+		}
+		return p.typ < q.typ
+	})
+	for _, f := range found {
+		fmt.Fprintf(a.w, "%s:%s:%s\n", f.key, f.name, f.typ)
+	}
+
+	return tx.Commit()
+}
+
+// matchName matches a decrypted name the same way cmdFind matches stored
+// names, with globs left to the database.
+func matchName(tx *sql.Tx, name, pattern string, glob bool, re *regexp.Regexp) (bool, error) {
+	switch {
+	case pattern == "":
+		return true, nil
+	case re != nil:
+		return re.MatchString(name), nil
+	case glob:
+		var ok bool
+		err := tx.QueryRow(`SELECT ? GLOB ?`, name, pattern).Scan(&ok)
+		return ok, err
+	default:
+		return strings.Contains(strings.ToLower(name), strings.ToLower(pattern)), nil
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCmdFind(t *testing.T) {
+	ctx := context.Background()
+	app, out := testNewApp(t, &testPinentry{pass: "pass-1"})
+
+	for _, args := range [][]string{
+		{"new", "test-1:github.com/me:pass"},
+		{"new", "test-1:github.com/me:totp"},
+		{"new", "test-1:gitlab.com:pass"},
+		{"tag", "test-1:github.com/me:pass", "work"},
+		{"tag", "test-1:gitlab.com:pass", "work", "ci"},
+		{"tag", "test-2:test-2:pass", "ci"},
+	} {
+		// The totp secret is read from pinentry as well
+		app.pin = &testPinentry{pass: "pass-1"}
+		if strings.HasSuffix(args[1], ":totp") {
+			app.pin = &testPinentry{pass: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"}
+		}
+		if err := app.run(ctx, args); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	tests := []struct {
+		args []string
+		out  string
+	}{
+		{[]string{"find"}, "test-1:github.com/me:pass\ntest-1:github.com/me:totp\ntest-1:gitlab.com:pass\ntest-1:test-1:pass\ntest-2:test-2:pass\n"},
+		{[]string{"find", "GIT"}, "test-1:github.com/me:pass\ntest-1:github.com/me:totp\ntest-1:gitlab.com:pass\n"},
+		{[]string{"find", "-glob", "git*.com"}, "test-1:gitlab.com:pass\n"},
+		{[]string{"find", "-regex", `^test-\d$`}, "test-1:test-1:pass\ntest-2:test-2:pass\n"},
+		{[]string{"find", "-type", "totp"}, "test-1:github.com/me:totp\n"},
+		{[]string{"find", "-tag", "work", "hub"}, "test-1:github.com/me:pass\n"},
+		{[]string{"find", "-tag", "ci,work"}, "test-1:gitlab.com:pass\n"},
+		{[]string{"find", "-tag", "ci", "-key", "test-2"}, "test-2:test-2:pass\n"},
+		{[]string{"find", "none"}, ""},
+	}
+
+	for _, tt := range tests {
+		out.Reset()
+		err := app.run(ctx, tt.args)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if out.String() != tt.out {
+			t.Errorf("%v out = %q; want %q", tt.args, out.String(), tt.out)
+		}
+	}
+}
+
+func TestCmdFindFail(t *testing.T) {
+	ctx := context.Background()
+	app, _ := testNewApp(t, &testPinentry{})
+
+	for _, args := range [][]string{
+		{"find", "a", "b"},
+		{"find", "-glob", "-regex", "a"},
+		{"find", "-invalid"},
+	} {
+		err := app.run(ctx, args)
+		if !errors.Is(err, errUsage) {
+			t.Errorf("%v err = %v; want %v", args, err, errUsage)
+		}
+	}
+
+	err := app.run(ctx, []string{"find", "-regex", "("})
+	if err == nil {
+		t.Errorf("find -regex err = %v; want error", err)
+	}
+}
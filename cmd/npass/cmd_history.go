@@ -0,0 +1,329 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+func (a *app) cmdHistory(ctx context.Context, args []string) error {
+	fs := newFlagSet("history")
+	prune := fs.Bool("prune", false, "prune previous versions")
+	keep := fs.Int64("keep", a.historyKeep, "number of versions kept per pass when pruning, or 0 for all")
+	maxAge := fs.Int64("max-age", a.historyMaxAge, "maximum age in days of versions kept when pruning, or 0 for any")
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	args = fs.Args()
+
+	if *keep < 0 || *maxAge < 0 {
+		return errUsage
+	}
+
+	var (
+		key, name, typ string
+		err            error
+	)
+	if len(args) >= 1 {
+		key, name, typ, err = parseIdentifier(args[0])
+		if err != nil {
+			return err
+		}
+	}
+
+	if *prune {
+		if len(args) > 1 || (name != "" && typ == "") {
+			return errUsage
+		}
+		return a.cmdHistoryPrune(ctx, key, name, typ, *keep, *maxAge)
+	}
+
+	if fs.NFlag() > 0 || key == "" || name == "" || typ == "" {
+		return errUsage
+	}
+
+	switch {
+	case len(args) == 1:
+		return a.cmdHistoryList(ctx, key, name, typ)
+	case len(args) >= 3 && args[1] == "show", len(args) == 3 && args[1] == "restore":
+		version, err := strconv.ParseInt(args[2], 10, 64)
+		if err != nil {
+			return errUsage
+		}
+		return a.cmdHistoryVersion(ctx, key, name, typ, version, args[1] == "restore", args[3:])
+	}
+
+	return errUsage
+}
+
+// dbVersion is a previous version of a pass, along with the identifier and
+// public key it was sealed to.
+type dbVersion struct {
+	id        int64
+	key       string
+	pub       string
+	name, typ string
+	data      string
+	created   int64
+}
+
+func (a *app) cmdHistoryList(ctx context.Context, key, name, typ string) error {
+	tx, err := a.st.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	k, err := queryKey(tx, key)
+	if err != nil {
+		return err
+	}
+	if err := a.openIndex(ctx, &k); err != nil {
+		return err
+	}
+	p, err := queryPass(tx, k, name, typ)
+	if err != nil {
+		return err
+	}
+
+	query := `SELECT id, key_name, key_public, created FROM history WHERE pass_id = ? ORDER BY id`
+	rows, err := tx.Query(query, p.id)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var v dbVersion
+		if err := rows.Scan(&v.id, &v.key, &v.pub, &v.created); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(a.w, "%d: replaced %s", v.id, time.Unix(v.created, 0).Format("2006-01-02 15:04"))
+		if v.key != k.name {
+			fmt.Fprintf(a.w, " (sealed to key %q)", v.key)
+		} else if v.pub != k.pub {
+			fmt.Fprintf(a.w, " (sealed to a previous keypair)")
+		}
+		fmt.Fprintln(a.w)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (a *app) cmdHistoryVersion(ctx context.Context, key, name, typ string, version int64, restore bool, op []string) error {
+	fullName := fmt.Sprintf("%s:%s:%s", key, name, typ)
+
+	tx, err := a.st.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	k, err := queryKey(tx, key)
+	if err != nil {
+		return err
+	}
+	if err := a.openIndex(ctx, &k); err != nil {
+		return err
+	}
+	p, err := queryPass(tx, k, name, typ)
+	if err != nil {
+		return err
+	}
+
+	v := dbVersion{id: version}
+	query := `SELECT key_name, key_public, name, type, data, created FROM history WHERE id = ? AND pass_id = ?`
+	err = tx.QueryRow(query, version, p.id).Scan(&v.key, &v.pub, &v.name, &v.typ, &v.data, &v.created)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("non-existent version %d of pass %q", version, fullName)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Versions are never resealed, so they can only be opened by the key
+	// that sealed them, if it still exists.
+	vk, err := queryKey(tx, v.key)
+	if err != nil {
+		return fmt.Errorf("version %d of pass %q is sealed to a removed key %q", version, fullName, v.key)
+	}
+	if vk.pub != v.pub {
+		return fmt.Errorf("version %d of pass %q is sealed to a previous keypair of key %q", version, fullName, v.key)
+	}
+
+	if vk.id == k.id {
+		vk = k
+	}
+
+	pub, err := decodePubKey(vk.pub)
+	if err != nil {
+		return err
+	}
+	priv, err := a.unlock(ctx, &vk)
+	if err != nil {
+		return err
+	}
+
+	dec, err := openPass(pub, priv, v.key, v.name, v.typ, v.data)
+	if err != nil {
+		return err
+	}
+
+	pass, err := newPass(typ)
+	if err != nil {
+		return err
+	}
+	if err := pass.UnmarshalText(dec); err != nil {
+		return err
+	}
+
+	// Changes to the state of previous versions are not stored
+	if !restore {
+		write, err := showPass(pass, typ, op)
+		if err != nil {
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		return write(a.w)
+	}
+
+	if err := a.recordHistory(tx, k, p); err != nil {
+		return err
+	}
+	if err := updatePass(tx, k, p, pass); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(a.w, "restored version %d of pass %q\n", version, fullName)
+	return nil
+}
+
+func (a *app) cmdHistoryPrune(ctx context.Context, key, name, typ string, keep, maxAge int64) error {
+	tx, err := a.st.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var (
+		query     = `SELECT pass.id FROM pass`
+		queryArgs []interface{}
+	)
+	if key != "" {
+		k, err := queryKey(tx, key)
+		if err != nil {
+			return err
+		}
+		query += ` WHERE key_id = ?`
+		queryArgs = append(queryArgs, k.id)
+
+		if name != "" {
+			if err := a.openIndex(ctx, &k); err != nil {
+				return err
+			}
+			p, err := queryPass(tx, k, name, typ)
+			if err != nil {
+				return err
+			}
+			query += ` AND id = ?`
+			queryArgs = append(queryArgs, p.id)
+		}
+	}
+
+	rows, err := tx.Query(query, queryArgs...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return err
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	var pruned int64
+	for _, id := range ids {
+		n, err := pruneHistory(tx, id, keep, maxAge)
+		if err != nil {
+			return err
+		}
+		pruned += n
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(a.w, "pruned %d previous versions\n", pruned)
+	return nil
+}
+
+// recordHistory saves the current version of p before it is replaced, and
+// prunes its history according to the configured policy.
+func (a *app) recordHistory(tx *sql.Tx, k dbKey, p dbPass) error {
+	query := `INSERT INTO history (pass_id, key_name, key_public, name, type, data, created) VALUES(?, ?, ?, ?, ?, ?, ?)`
+	_, err := tx.Exec(query, p.id, k.name, k.pub, p.idxName, p.idxType, p.data, timeNow().Unix())
+	if err != nil {
+		return err
+	}
+
+	_, err = pruneHistory(tx, p.id, a.historyKeep, a.historyMaxAge)
+	return err
+}
+
+// pruneHistory removes all but the latest keep versions of a pass, and those
+// older than maxAge days, returning the number of versions removed.
+func pruneHistory(tx *sql.Tx, passID, keep, maxAge int64) (int64, error) {
+	var pruned int64
+
+	if keep > 0 {
+		query := `DELETE FROM history WHERE pass_id = ? AND id NOT IN
+	(SELECT id FROM history WHERE pass_id = ? ORDER BY id DESC LIMIT ?)`
+		res, err := tx.Exec(query, passID, passID, keep)
+		if err != nil {
+			return 0, err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		pruned += n
+	}
+
+	if maxAge > 0 {
+		before := timeNow().AddDate(0, 0, -int(maxAge)).Unix()
+		query := `DELETE FROM history WHERE pass_id = ? AND created < ?`
+		res, err := tx.Exec(query, passID, before)
+		if err != nil {
+			return 0, err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		pruned += n
+	}
+
+	return pruned, nil
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testHistoryApp(t *testing.T) (*app, *testPinentry, *bytes.Buffer) {
+	ctx := context.Background()
+	pin := &testPinentry{pass: "pass-1"}
+	app, out := testNewApp(t, pin)
+
+	now := time.Date(2020, 1, 1, 12, 0, 0, 0, time.Local)
+	for i, pass := range []string{"pass-2", "pass-3"} {
+		testTimeNow(t, now.AddDate(0, 0, i))
+		pin.newPass = pass
+		if err := app.run(ctx, []string{"edit", "test-1:test-1:pass"}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	pin.newPass = ""
+	out.Reset()
+
+	return app, pin, out
+}
+
+func TestCmdHistory(t *testing.T) {
+	ctx := context.Background()
+	app, _, out := testHistoryApp(t)
+
+	tests := []struct {
+		args []string
+		out  string
+	}{
+		{[]string{"history", "test-1:test-1:pass"}, "1: replaced 2020-01-01 12:00\n2: replaced 2020-01-02 12:00\n"},
+		{[]string{"history", "test-1:test-1:pass", "show", "1"}, "pass-1\n"},
+		{[]string{"history", "test-1:test-1:pass", "show", "2"}, "pass-2\n"},
+		{[]string{"history", "test-1:test-1:pass", "restore", "1"}, "restored version 1 of pass \"test-1:test-1:pass\"\n"},
+		{[]string{"show", "test-1:test-1:pass"}, "pass-1\n"},
+		{[]string{"history", "test-1:test-1:pass", "show", "3"}, "pass-3\n"},
+		{[]string{"history", "-prune", "-keep", "1"}, "pruned 2 previous versions\n"},
+		{[]string{"history", "test-1:test-1:pass"}, "3: replaced 2020-01-02 12:00\n"},
+	}
+
+	for _, tt := range tests {
+		out.Reset()
+		err := app.run(ctx, tt.args)
+		if err != nil {
+			t.Fatalf("%v: unexpected error: %v", tt.args, err)
+		}
+		if out.String() != tt.out {
+			t.Errorf("%v out = %q; want %q", tt.args, out.String(), tt.out)
+		}
+	}
+}
+
+func TestCmdHistoryPruneAge(t *testing.T) {
+	ctx := context.Background()
+	app, _, out := testHistoryApp(t)
+
+	testTimeNow(t, time.Date(2020, 1, 3, 0, 0, 0, 0, time.Local))
+	err := app.run(ctx, []string{"history", "-prune", "-max-age", "1", "test-1:test-1:pass"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "pruned 1 previous versions\n"; out.String() != want {
+		t.Errorf("history -prune out = %q; want %q", out.String(), want)
+	}
+}
+
+func TestCmdHistoryKeep(t *testing.T) {
+	ctx := context.Background()
+	app, pin, _ := testHistoryApp(t)
+	app.historyKeep = 1
+
+	pin.newPass = "pass-4"
+	if err := app.run(ctx, []string{"edit", "test-1:test-1:pass"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var count int
+	if err := app.st.QueryRow(`SELECT COUNT(*) FROM history`).Scan(&count); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("history count = %d; want %d", count, 1)
+	}
+}
+
+func TestCmdHistoryRotated(t *testing.T) {
+	ctx := context.Background()
+	app, _, _ := testHistoryApp(t)
+
+	if err := app.run(ctx, []string{"rotate", "test-1"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Old versions stay sealed to the old keypair
+	err := app.run(ctx, []string{"history", "test-1:test-1:pass", "show", "1"})
+	want := fmt.Errorf("version %d of pass %q is sealed to a previous keypair of key %q", 1, "test-1:test-1:pass", "test-1")
+	if !reflect.DeepEqual(err, want) {
+		t.Errorf("history show err = %v; want %v", err, want)
+	}
+}
+
+func TestCmdHistoryFail(t *testing.T) {
+	ctx := context.Background()
+	app, _, _ := testHistoryApp(t)
+
+	tests := []struct {
+		args []string
+		err  error
+	}{
+		{[]string{"history"}, errUsage},
+		{[]string{"history", "test-1:test-1"}, errUsage},
+		{[]string{"history", "-keep", "1", "test-1:test-1:pass"}, errUsage},
+		{[]string{"history", "-prune", "test-1:test-1"}, errUsage},
+		{[]string{"history", "test-1:test-1:pass", "show"}, errUsage},
+		{[]string{"history", "test-1:test-1:pass", "show", "x"}, errUsage},
+		{[]string{"history", "test-1:test-1:pass", "show", "9"},
+			fmt.Errorf("non-existent version %d of pass %q", 9, "test-1:test-1:pass")},
+		{[]string{"history", "test-2:test-2:pass", "show", "1"},
+			fmt.Errorf("non-existent version %d of pass %q", 1, "test-2:test-2:pass")},
+	}
+
+	for _, tt := range tests {
+		err := app.run(ctx, tt.args)
+		if !errors.Is(err, tt.err) && !reflect.DeepEqual(err, tt.err) {
+			t.Errorf("%v err = %v; want %v", tt.args, err, tt.err)
+		}
+	}
+}
+
+func TestCmdHistoryShowNote(t *testing.T) {
+	ctx := context.Background()
+	app, out := testNewApp(t, &testPinentry{pass: "pass-1"})
+
+	app.r = strings.NewReader("abc")
+	if err := app.run(ctx, []string{"new", "test-1:a:note"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	testEditor(t, `printf 'def\n' > "$1"`)
+	if err := app.run(ctx, []string{"edit", "test-1:a:note"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Previous versions are shown exactly as the current one would be
+	out.Reset()
+	if err := app.run(ctx, []string{"history", "test-1:a:note", "show", "1"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "abc"; out.String() != want {
+		t.Errorf("history show out = %q; want %q", out.String(), want)
+	}
+
+	err := app.run(ctx, []string{"history", "test-1:a:note", "show", "1", "info"})
+	if want := fmt.Errorf("pass type %q does not support operations", "note"); !reflect.DeepEqual(err, want) {
+		t.Errorf("history show err = %v; want %v", err, want)
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+func (a *app) cmdMv(ctx context.Context, args []string) error {
+	if len(args) != 2 {
+		return errUsage
+	}
+
+	srcKey, srcName, srcTyp, err := parseIdentifier(args[0])
+	if err != nil {
+		return err
+	}
+	dstKey, dstName, dstTyp, err := parseIdentifier(args[1])
+	if err != nil {
+		return err
+	}
+
+	if srcName == "" || dstName == "" {
+		return errUsage
+	}
+	if srcTyp == "" && dstTyp != "" {
+		return errUsage
+	}
+	if dstTyp != "" && dstTyp != srcTyp {
+		return fmt.Errorf("cannot change pass type from %q to %q", srcTyp, dstTyp)
+	}
+	if srcKey == dstKey && srcName == dstName {
+		return fmt.Errorf("source and destination are the same")
+	}
+
+	tx, err := a.st.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	src, err := queryKey(tx, srcKey)
+	if err != nil {
+		return err
+	}
+	if err := a.openIndex(ctx, &src); err != nil {
+		return err
+	}
+	dst := src
+	if dstKey != srcKey {
+		dst, err = queryKey(tx, dstKey)
+		if err != nil {
+			return err
+		}
+		if err := a.openIndex(ctx, &dst); err != nil {
+			return err
+		}
+	}
+
+	var pass []dbPass
+	if srcTyp != "" {
+		p, err := queryPass(tx, src, srcName, srcTyp)
+		if err != nil {
+			return err
+		}
+		pass = append(pass, p)
+	} else {
+		idxName, _, err := src.passIndex(srcName, "")
+		if err != nil {
+			return err
+		}
+		pass, err = queryPasses(tx, src, `name = ? AND trashed IS NULL`, idxName)
+		if err != nil {
+			return err
+		}
+		if err := namePasses(src, pass); err != nil {
+			return err
+		}
+
+		if len(pass) == 0 {
+			return fmt.Errorf("non-existent pass %q", fmt.Sprintf("%s:%s", srcKey, srcName))
+		}
+	}
+
+	type move struct {
+		idxName, idxType string
+		sealedName       sql.NullString
+	}
+	moves := make([]move, len(pass))
+
+	queryExists := `SELECT trashed FROM pass WHERE key_id = ? AND name = ? AND type = ?`
+	for i, p := range pass {
+		dstFull := fmt.Sprintf("%s:%s:%s", dstKey, dstName, p.typ)
+
+		m := &moves[i]
+		m.idxName, m.idxType, err = dst.passIndex(dstName, p.typ)
+		if err != nil {
+			return err
+		}
+		m.sealedName, err = dst.sealName(dstName, p.typ, m.idxName, m.idxType)
+		if err != nil {
+			return err
+		}
+
+		var trashed sql.NullInt64
+		err := tx.QueryRow(queryExists, dst.id, m.idxName, m.idxType).Scan(&trashed)
+		if err == nil && trashed.Valid {
+			return fmt.Errorf("pass %q is in the trash", dstFull)
+		}
+		if err == nil {
+			return fmt.Errorf("duplicate pass %q", dstFull)
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+	}
+
+	srcPub, err := decodePubKey(src.pub)
+	if err != nil {
+		return err
+	}
+	dstPub, err := decodePubKey(dst.pub)
+	if err != nil {
+		return err
+	}
+
+	srcPriv, err := a.unlock(ctx, &src)
+	if err != nil {
+		return err
+	}
+
+	queryUpdate := `UPDATE pass SET key_id = ?, name = ?, type = ?, sealed_name = ?, data = ? WHERE id = ?`
+	for i, p := range pass {
+		srcFull := fmt.Sprintf("%s:%s:%s", srcKey, srcName, p.typ)
+		dstFull := fmt.Sprintf("%s:%s:%s", dstKey, dstName, p.typ)
+		m := moves[i]
+
+		dec, err := openPass(srcPub, srcPriv, srcKey, p.idxName, p.idxType, p.data)
+		if err != nil {
+			return fmt.Errorf("could not decrypt pass %q: %w", srcFull, err)
+		}
+
+		enc, err := sealPass(dstPub, dstKey, m.idxName, m.idxType, dec, dst.padding)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(queryUpdate, dst.id, m.idxName, m.idxType, m.sealedName, enc, p.id)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(a.w, "moved pass %q to %q\n", srcFull, dstFull)
+	}
+
+	return tx.Commit()
+}
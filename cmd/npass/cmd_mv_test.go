@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestCmdMv(t *testing.T) {
+	ctx := context.Background()
+	app, _ := testNewApp(t, &testPinentry{})
+
+	for _, args := range [][]string{
+		{"mv"},
+		{"mv", "test-1:test-1"},
+		{"mv", "test-1", "test-2:test-1"},
+		{"mv", "test-1:test-1", "test-2"},
+		{"mv", "test-1:test-1", "test-2:test-1:pass"},
+	} {
+		err := app.run(ctx, args)
+		if !errors.Is(err, errUsage) {
+			t.Errorf("%v err = %v; want %v", args, err, errUsage)
+		}
+	}
+
+	err := app.run(ctx, []string{"mv", "INVALID", "test-1:test-1"})
+	if !errors.Is(err, errIdentifier) {
+		t.Errorf("mv err = %v; want %v", err, errIdentifier)
+	}
+}
+
+func TestCmdMvRename(t *testing.T) {
+	ctx := context.Background()
+	pin := &testPinentry{pass: "pass-1"}
+	app, out := testNewApp(t, pin)
+
+	err := app.run(ctx, []string{"mv", "test-1:test-1:pass", "test-1:renamed"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := fmt.Sprintf("moved pass %q to %q\n", "test-1:test-1:pass", "test-1:renamed:pass")
+	if out.String() != want {
+		t.Errorf("mv out = %q; want %q", out.String(), want)
+	}
+
+	out.Reset()
+	err = app.run(ctx, []string{"show", "test-1:renamed:pass"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "pass-1\n"; out.String() != want {
+		t.Errorf("show (pass) out = %q; want %q", out.String(), want)
+	}
+}
+
+func TestCmdMvKey(t *testing.T) {
+	ctx := context.Background()
+	pin := &testPinentry{pass: "pass-1"}
+	app, out := testNewApp(t, pin)
+
+	err := app.run(ctx, []string{"mv", "test-1:test-1", "test-2:moved"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := fmt.Sprintf("moved pass %q to %q\n", "test-1:test-1:pass", "test-2:moved:pass")
+	if out.String() != want {
+		t.Errorf("mv out = %q; want %q", out.String(), want)
+	}
+
+	var id int64
+	err = app.st.QueryRow(`SELECT id FROM pass WHERE key_id = 2 AND name = 'moved'`).Scan(&id)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if id != 1 {
+		t.Errorf("pass id = %d; want %d", id, 1)
+	}
+
+	out.Reset()
+	pin.pass = "pass-2"
+	err = app.run(ctx, []string{"show", "test-2:moved:pass"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "pass-1\n"; out.String() != want {
+		t.Errorf("show (pass) out = %q; want %q", out.String(), want)
+	}
+}
+
+func TestCmdMvTypeFail(t *testing.T) {
+	ctx := context.Background()
+	app, _ := testNewApp(t, &testPinentry{})
+
+	err := app.run(ctx, []string{"mv", "test-1:test-1:pass", "test-1:new:other"})
+	if want := fmt.Errorf("cannot change pass type from %q to %q", "pass", "other"); !reflect.DeepEqual(err, want) {
+		t.Errorf("mv err = %v; want %v", err, want)
+	}
+}
+
+func TestCmdMvSameFail(t *testing.T) {
+	ctx := context.Background()
+	app, _ := testNewApp(t, &testPinentry{})
+
+	err := app.run(ctx, []string{"mv", "test-1:test-1", "test-1:test-1"})
+	if want := fmt.Errorf("source and destination are the same"); !reflect.DeepEqual(err, want) {
+		t.Errorf("mv err = %v; want %v", err, want)
+	}
+}
+
+func TestCmdMvKeyFail(t *testing.T) {
+	ctx := context.Background()
+	app, _ := testNewApp(t, &testPinentry{})
+
+	err := app.run(ctx, []string{"mv", "test-1:test-1", "test-none:test-1"})
+	if want := fmt.Errorf("non-existent key %q", "test-none"); !reflect.DeepEqual(err, want) {
+		t.Errorf("mv err = %v; want %v", err, want)
+	}
+}
+
+func TestCmdMvNameFail(t *testing.T) {
+	ctx := context.Background()
+	app, _ := testNewApp(t, &testPinentry{})
+
+	err := app.run(ctx, []string{"mv", "test-1:none", "test-1:other"})
+	if want := fmt.Errorf("non-existent pass %q", "test-1:none"); !reflect.DeepEqual(err, want) {
+		t.Errorf("mv err = %v; want %v", err, want)
+	}
+}
+
+func TestCmdMvDuplicateFail(t *testing.T) {
+	ctx := context.Background()
+	app, _ := testNewApp(t, &testPinentry{})
+
+	err := app.run(ctx, []string{"mv", "test-1:test-1", "test-2:test-2"})
+	if want := fmt.Errorf("duplicate pass %q", "test-2:test-2:pass"); !reflect.DeepEqual(err, want) {
+		t.Errorf("mv err = %v; want %v", err, want)
+	}
+}
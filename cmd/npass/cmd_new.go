@@ -1,20 +1,56 @@
 package main
 
 import (
-	"bytes"
 	"context"
 	"crypto/rand"
 	"database/sql"
 	"encoding/base64"
 	"errors"
+	"flag"
 	"fmt"
 	"strings"
 
 	"golang.org/x/crypto/nacl/box"
-	"golang.org/x/crypto/nacl/secretbox"
 )
 
+type newOpts struct {
+	gen   generator
+	print bool
+
+	// KDF parameters of new keys, and whether they were calibrated.
+	kdf        kdfParams
+	calibrated bool
+	// Whether new keys store the names of their passes privately.
+	privateIndex bool
+	// Minimum padded size of the passes of new keys.
+	padding int
+}
+
 func (a *app) cmdNew(ctx context.Context, args []string) error {
+	fs := newFlagSet("new")
+	gen := fs.Bool("gen", false, "generate a random password")
+	length := fs.Int("length", 24, "length of generated passwords")
+	classes := fs.String("classes", defaultPasswordClasses, "required character classes of generated passwords")
+	charset := fs.String("charset", "", "custom alphabet for generated passwords")
+	noAmbiguous := fs.Bool("no-ambiguous", false, "exclude ambiguous characters from generated passwords")
+	diceware := fs.Bool("diceware", false, "generate a diceware passphrase")
+	words := fs.Int("words", 6, "number of words in generated passphrases")
+	separator := fs.String("separator", " ", "separator between words in generated passphrases")
+	capitalize := fs.Bool("capitalize", false, "capitalize words in generated passphrases")
+	wordlist := fs.String("wordlist", "", "custom wordlist file for generated passphrases")
+	printGen := fs.Bool("print", false, "print the generated value")
+	in := fs.String("in", "", "read the pass from this file instead of stdin")
+	kdf := addKDFFlags(fs)
+	privateIndex := fs.Bool("private-index", false, "store the names and types of the passes of new keys as HMACs; key names stay in cleartext")
+	padding := fs.Int("padding", defaultPadding, "pad the passes of new keys to a power of two of at least this many bytes, or 0 to disable")
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	args = fs.Args()
+
+	var paddingSet bool
+	fs.Visit(func(f *flag.Flag) { paddingSet = paddingSet || f.Name == "padding" })
+
 	if len(args) != 1 {
 		return errUsage
 	}
@@ -24,69 +60,126 @@ func (a *app) cmdNew(ctx context.Context, args []string) error {
 		return err
 	}
 
+	if *gen && *diceware {
+		return errUsage
+	}
+	if *in != "" && (*gen || *diceware || typ == "") {
+		return errUsage
+	}
+
+	opts := newOpts{print: *printGen, privateIndex: *privateIndex, padding: *padding}
+	if *gen {
+		opts.gen, err = newPasswordGenerator(*length, *classes, *charset, *noAmbiguous)
+		if err != nil {
+			return err
+		}
+	}
+	if *diceware {
+		opts.gen, err = newPassphraseGenerator(*words, *separator, *capitalize, *wordlist)
+		if err != nil {
+			return err
+		}
+	}
+
 	if key != "" && name != "" && typ != "" {
-		return a.cmdNewPass(ctx, key, name, typ)
+		if kdf.set() || *privateIndex || paddingSet {
+			return errUsage
+		}
+
+		done, err := a.openInput(*in)
+		if err != nil {
+			return err
+		}
+		defer done()
+
+		return a.cmdNewPass(ctx, key, name, typ, opts)
 	}
-	if key != "" && name == "" && typ == "" {
-		return a.cmdNewKey(ctx, key)
+	// Key passwords must be remembered, so only passphrases are allowed.
+	if key != "" && name == "" && typ == "" && !*gen {
+		opts.kdf, opts.calibrated, err = kdf.params(defaultKDF)
+		if err != nil {
+			return err
+		}
+		if err := checkPadding(opts.padding); err != nil {
+			return err
+		}
+		return a.cmdNewKey(ctx, key, opts)
 	}
 
 	return errUsage
 }
 
-func (a *app) cmdNewKey(ctx context.Context, key string) error {
+func (a *app) cmdNewKey(ctx context.Context, key string, opts newOpts) error {
 	tx, err := a.st.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 	defer func() { _ = tx.Rollback() }()
 
-	var exists bool
-	queryExists := `SELECT EXISTS(SELECT 1 FROM keys WHERE name = ?)`
-	err = tx.QueryRow(queryExists, key).Scan(&exists)
-	if err != nil {
-		return err
+	var trashed sql.NullInt64
+	queryExists := `SELECT trashed FROM keys WHERE name = ?`
+	err = tx.QueryRow(queryExists, key).Scan(&trashed)
+	if err == nil && trashed.Valid {
+		return fmt.Errorf("key %q is in the trash", key)
 	}
-	if exists {
+	if err == nil {
 		return fmt.Errorf("duplicate key %q", key)
 	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
 
 	pub, priv, err := box.GenerateKey(rand.Reader)
 	if err != nil {
 		return err
 	}
 
-	salt := make([]byte, 16)
-	if _, err := rand.Read(salt); err != nil {
+	var pass string
+	if opts.gen != nil {
+		pass, err = opts.gen.generate()
+	} else {
+		pass, err = a.pin.NewPass(ctx, fmt.Sprintf("Enter password for key %q:", key))
+	}
+	if err != nil {
 		return err
 	}
 
-	pass, err := a.pin.NewPass(ctx, fmt.Sprintf("Enter password for key %q:", key))
+	privEnc, err := sealPrivKey(pass, priv, opts.kdf)
 	if err != nil {
 		return err
 	}
 
-	pkey := passKey(pass, salt)
-	var keyArr [32]byte
-	copy(keyArr[:], pkey)
-
-	privEnc := secretbox.Seal(salt, priv[:], &[24]byte{}, &keyArr)
-
-	queryInsert := `INSERT INTO keys (name, public, private) VALUES(?, ?, ?)`
+	now := timeNow().Unix()
+	queryInsert := `INSERT INTO keys (name, public, private, private_index, padding, created, updated) VALUES(?, ?, ?, ?, ?, ?, ?)`
 	_, err = tx.Exec(queryInsert,
 		key,
 		base64.RawStdEncoding.EncodeToString(pub[:]),
-		base64.RawStdEncoding.EncodeToString(privEnc),
+		privEnc,
+		opts.privateIndex,
+		opts.padding,
+		now, now,
 	)
 	if err != nil {
 		return err
 	}
 
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
 	fmt.Fprintf(a.w, "created new key %q: %s\n", key, base64.RawStdEncoding.EncodeToString(pub[:]))
-	return tx.Commit()
+	if opts.calibrated {
+		fmt.Fprintf(a.w, "calibrated KDF: %s\n", opts.kdf)
+	}
+	if opts.gen != nil {
+		// The generated password is not stored anywhere else.
+		fmt.Fprintf(a.w, "generated password has %.1f bits of entropy\n", opts.gen.entropy())
+		fmt.Fprintln(a.w, pass)
+	}
+	return nil
 }
 
-func (a *app) cmdNewPass(ctx context.Context, key, name, typ string) error {
+func (a *app) cmdNewPass(ctx context.Context, key, name, typ string, opts newOpts) error {
 	fullName := strings.Join([]string{key, name, typ}, ":")
 
 	tx, err := a.st.BeginTx(ctx, nil)
@@ -95,15 +188,7 @@ func (a *app) cmdNewPass(ctx context.Context, key, name, typ string) error {
 	}
 	defer func() { _ = tx.Rollback() }()
 
-	var (
-		keyID  int64
-		keyPub string
-	)
-	queryKey := `SELECT id, public FROM keys WHERE name = ? LIMIT 1`
-	err = tx.QueryRow(queryKey, key).Scan(&keyID, &keyPub)
-	if errors.Is(err, sql.ErrNoRows) {
-		return fmt.Errorf("non-existent key %q", key)
-	}
+	k, err := queryKey(tx, key)
 	if err != nil {
 		return err
 	}
@@ -113,49 +198,100 @@ func (a *app) cmdNewPass(ctx context.Context, key, name, typ string) error {
 		return err
 	}
 
-	var exists bool
-	queryExists := `SELECT EXISTS(SELECT 1 FROM pass WHERE key_id = ? AND name = ? AND type = ?)`
-	err = tx.QueryRow(queryExists, keyID, name, typ).Scan(&exists)
+	if err := a.openIndex(ctx, &k); err != nil {
+		return err
+	}
+	idxName, idxType, err := k.passIndex(name, typ)
 	if err != nil {
 		return err
 	}
-	if exists {
+
+	var trashed sql.NullInt64
+	queryExists := `SELECT trashed FROM pass WHERE key_id = ? AND name = ? AND type = ?`
+	err = tx.QueryRow(queryExists, k.id, idxName, idxType).Scan(&trashed)
+	if err == nil && trashed.Valid {
+		return fmt.Errorf("pass %q is in the trash", fullName)
+	}
+	if err == nil {
 		return fmt.Errorf("duplicate pass %q", fullName)
 	}
-
-	err = ptype.readPass(ctx, a, name)
-	if err != nil {
+	if !errors.Is(err, sql.ErrNoRows) {
 		return err
 	}
 
+	var (
+		generated string
+		entropy   float64
+	)
+	if opts.gen != nil {
+		switch gp := ptype.(type) {
+		case passGenerated:
+			generated, err = opts.gen.generate()
+			if err != nil {
+				return err
+			}
+			if err := gp.setGenerated(generated); err != nil {
+				return err
+			}
+			entropy = opts.gen.entropy()
+		case passSelfGenerated:
+			if err := gp.generatePass(); err != nil {
+				return err
+			}
+			generated, err = gp.printPass()
+			if err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("pass type %q cannot be generated", typ)
+		}
+	} else {
+		err = ptype.readPass(ctx, a, name)
+		if err != nil {
+			return err
+		}
+	}
+
 	passData, err := ptype.MarshalText()
 	if err != nil {
 		return err
 	}
-	passData = bytes.Join([][]byte{[]byte(fullName), passData}, []byte(":"))
 
-	keyPubRaw, err := base64.RawStdEncoding.DecodeString(keyPub)
+	keyPubArr, err := decodePubKey(k.pub)
 	if err != nil {
 		return err
 	}
 
-	var keyPubArr [32]byte
-	copy(keyPubArr[:], keyPubRaw)
-
-	passEnc, err := box.SealAnonymous(nil, passData, &keyPubArr, rand.Reader)
+	passEnc, err := sealPass(keyPubArr, key, idxName, idxType, passData, k.padding)
+	if err != nil {
+		return err
+	}
+	sealedName, err := k.sealName(name, typ, idxName, idxType)
 	if err != nil {
 		return err
 	}
 
-	queryInsert := `INSERT INTO pass (key_id, name, type, data) VALUES(?, ?, ?, ?)`
+	now := timeNow().Unix()
+	queryInsert := `INSERT INTO pass (key_id, name, type, sealed_name, data, created, updated) VALUES(?, ?, ?, ?, ?, ?, ?)`
 	_, err = tx.Exec(queryInsert,
-		keyID, name, typ,
-		base64.RawStdEncoding.EncodeToString(passEnc),
+		k.id, idxName, idxType, sealedName, passEnc, now, now,
 	)
 	if err != nil {
 		return err
 	}
 
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
 	fmt.Fprintf(a.w, "created new pass %q\n", fullName)
-	return tx.Commit()
+	if opts.gen != nil {
+		if entropy > 0 {
+			fmt.Fprintf(a.w, "generated value has %.1f bits of entropy\n", entropy)
+		}
+		if opts.print {
+			fmt.Fprintln(a.w, generated)
+		}
+	}
+	return nil
 }
@@ -127,3 +127,225 @@ func TestCmdNewPassPinFail(t *testing.T) {
 		t.Errorf("new (pass) err = %v; want %v", err, pin.err)
 	}
 }
+
+func TestCmdNewPassGen(t *testing.T) {
+	ctx := context.Background()
+	pin := &testPinentry{pass: "pass-1"}
+	app, out := testNewApp(t, pin)
+
+	err := app.run(ctx, []string{"new", "-gen", "-length", "32", "-print", "test-1:gen:pass"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	lines := strings.Split(out.String(), "\n")
+	if len(lines) != 4 || len(lines[2]) != 32 {
+		t.Fatalf("new (pass) out = %q; want created message, entropy and password", out.String())
+	}
+	generated := lines[2]
+
+	out.Reset()
+	err = app.run(ctx, []string{"show", "test-1:gen:pass"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := generated + "\n"; out.String() != want {
+		t.Errorf("show (pass) out = %q; want %q", out.String(), want)
+	}
+}
+
+func TestCmdNewPassGenNoPrint(t *testing.T) {
+	ctx := context.Background()
+	app, out := testNewApp(t, &testPinentry{})
+
+	err := app.run(ctx, []string{"new", "-gen", "test-1:gen:pass"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := fmt.Sprintf("created new pass %q\ngenerated value has ", "test-1:gen:pass")
+	if !strings.HasPrefix(out.String(), want) || strings.Count(out.String(), "\n") != 2 {
+		t.Errorf("new (pass) out = %q; want prefix %q and no password", out.String(), want)
+	}
+}
+
+func TestCmdNewPassGenFail(t *testing.T) {
+	ctx := context.Background()
+	app, _ := testNewApp(t, &testPinentry{})
+
+	err := app.run(ctx, []string{"new", "-gen", "-length", "1", "test-1:gen:pass"})
+	if !errors.Is(err, errGenerator) {
+		t.Errorf("new (pass) err = %v; want %v", err, errGenerator)
+	}
+
+	err = app.run(ctx, []string{"new", "-gen", "test-key"})
+	if !errors.Is(err, errUsage) {
+		t.Errorf("new (key) err = %v; want %v", err, errUsage)
+	}
+
+	err = app.run(ctx, []string{"new", "-gen", "-diceware", "test-1:gen:pass"})
+	if !errors.Is(err, errUsage) {
+		t.Errorf("new (pass) err = %v; want %v", err, errUsage)
+	}
+
+	err = app.run(ctx, []string{"new", "-invalid", "test-1:gen:pass"})
+	if !errors.Is(err, errUsage) {
+		t.Errorf("new (pass) err = %v; want %v", err, errUsage)
+	}
+}
+
+func TestCmdNewKeyDiceware(t *testing.T) {
+	ctx := context.Background()
+	pin := &testPinentry{}
+	app, out := testNewApp(t, pin)
+
+	err := app.run(ctx, []string{"new", "-diceware", "-words", "4", "-separator", "-", "test-none"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	lines := strings.Split(out.String(), "\n")
+	if len(lines) != 4 || strings.Count(lines[2], "-") < 3 {
+		t.Fatalf("new (key) out = %q; want created message, entropy and passphrase", out.String())
+	}
+	if want := "generated password has 51.7 bits of entropy"; lines[1] != want {
+		t.Errorf("new (key) out = %q; want %q", lines[1], want)
+	}
+
+	pin.pass = lines[2]
+	err = app.run(ctx, []string{"passwd", "test-none"})
+	if err != nil {
+		t.Errorf("could not unlock key with generated passphrase: %v", err)
+	}
+}
+
+func TestCmdNewKeyKDF(t *testing.T) {
+	ctx := context.Background()
+	pin := &testPinentry{pass: "pass"}
+	app, _ := testNewApp(t, pin)
+
+	err := app.run(ctx, []string{"new", "-kdf-time", "2", "-kdf-memory", "1", "-kdf-threads", "2", "test-none"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var priv string
+	if err := app.st.QueryRow(`SELECT private FROM keys WHERE name = 'test-none'`).Scan(&priv); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	p, err := privKeyParams(priv)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := (kdfParams{Time: 2, Memory: 1024, Threads: 2}); p != want {
+		t.Errorf("KDF = %v; want %v", p, want)
+	}
+
+	err = app.run(ctx, []string{"passwd", "test-none"})
+	if err != nil {
+		t.Errorf("could not unlock key: %v", err)
+	}
+}
+
+func TestCmdNewKeyKDFCalibrate(t *testing.T) {
+	ctx := context.Background()
+	pin := &testPinentry{pass: "pass"}
+	app, out := testNewApp(t, pin)
+
+	err := app.run(ctx, []string{"new", "-kdf-target", "1ms", "-kdf-memory", "1", "-kdf-threads", "1", "test-none"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	lines := strings.Split(out.String(), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[1], "calibrated KDF: argon2id (") {
+		t.Errorf("new (key) out = %q; want created and calibrated messages", out.String())
+	}
+
+	var priv string
+	if err := app.st.QueryRow(`SELECT private FROM keys WHERE name = 'test-none'`).Scan(&priv); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	p, err := privKeyParams(priv)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if p.Memory != 1024 || p.Threads != 1 {
+		t.Errorf("calibrated KDF = %v; want memory 1024 KiB and 1 thread", p)
+	}
+}
+
+func TestCmdNewKeyKDFFail(t *testing.T) {
+	ctx := context.Background()
+	app, _ := testNewApp(t, &testPinentry{pass: "pass"})
+
+	for _, args := range [][]string{
+		{"new", "-kdf-time", "2", "test-1:test-new:pass"},
+		{"new", "-kdf-target", "1s", "-kdf-time", "2", "test-none"},
+		{"new", "-kdf-target", "-1s", "test-none"},
+		{"new", "-kdf-threads", "256", "test-none"},
+		{"new", "-padding", "64", "test-1:test-new:pass"},
+	} {
+		err := app.run(ctx, args)
+		if !errors.Is(err, errUsage) {
+			t.Errorf("%q err = %v; want %v", args, err, errUsage)
+		}
+	}
+
+	err := app.run(ctx, []string{"new", "-kdf-memory", "1", "-kdf-threads", "255", "test-none"})
+	if !errors.Is(err, errKDFParams) {
+		t.Errorf("new (key) err = %v; want %v", err, errKDFParams)
+	}
+
+	for _, args := range [][]string{
+		{"new", "-kdf-time", "1025", "test-none"},
+		{"new", "-kdf-memory", "16385", "test-none"},
+		{"new", "-kdf-target", "1s", "-kdf-memory", "16385", "test-none"},
+	} {
+		if err := app.run(ctx, args); !errors.Is(err, errKDFParams) {
+			t.Errorf("%q err = %v; want %v", args, err, errKDFParams)
+		}
+	}
+
+	err = app.run(ctx, []string{"new", "-padding", "3", "test-none"})
+	if !errors.Is(err, errPadding) {
+		t.Errorf("new (key) err = %v; want %v", err, errPadding)
+	}
+}
+
+func TestCmdNewKeyPadding(t *testing.T) {
+	ctx := context.Background()
+	app, _ := testNewApp(t, &testPinentry{pass: "pass"})
+
+	for _, tc := range []struct {
+		args    []string
+		key     string
+		padding int
+	}{
+		{[]string{"new", "test-default"}, "test-default", defaultPadding},
+		{[]string{"new", "-padding", "256", "test-256"}, "test-256", 256},
+		{[]string{"new", "-padding", "0", "test-none"}, "test-none", 0},
+	} {
+		if err := app.run(ctx, tc.args); err != nil {
+			t.Fatalf("%v: unexpected error: %v", tc.args, err)
+		}
+		if err := app.run(ctx, []string{"new", tc.key + ":name:pass"}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		var (
+			padding int
+			enc     string
+		)
+		query := `SELECT keys.padding, pass.data FROM pass JOIN keys ON pass.key_id = keys.id WHERE keys.name = ?`
+		if err := app.st.QueryRow(query, tc.key).Scan(&padding, &enc); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if padding != tc.padding {
+			t.Errorf("%v padding = %d; want %d", tc.args, padding, tc.padding)
+		}
+		if k := (dbKey{padding: padding}); !k.isCurrentPass(enc) {
+			t.Errorf("%v pass data = %q; want padded to %d", tc.args, enc, padding)
+		}
+	}
+}
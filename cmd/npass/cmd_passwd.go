@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+func (a *app) cmdPasswd(ctx context.Context, args []string) error {
+	fs := newFlagSet("passwd")
+	kdf := addKDFFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	args = fs.Args()
+
+	if len(args) != 1 {
+		return errUsage
+	}
+
+	key, name, typ, err := parseIdentifier(args[0])
+	if err != nil {
+		return err
+	}
+	if name != "" || typ != "" {
+		return errUsage
+	}
+
+	tx, err := a.st.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var (
+		kid     int64
+		keyPriv string
+	)
+	queryKey := `SELECT id, private FROM keys WHERE name = ? AND trashed IS NULL LIMIT 1`
+	err = tx.QueryRow(queryKey, key).Scan(&kid, &keyPriv)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("non-existent key %q", key)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Keys keep their KDF parameters unless new ones are given.
+	params, err := privKeyParams(keyPriv)
+	if err != nil {
+		return err
+	}
+	params, calibrated, err := kdf.params(params)
+	if err != nil {
+		return err
+	}
+
+	priv, _, err := a.unlockKey(ctx, key, keyPriv)
+	if err != nil {
+		return err
+	}
+
+	pass, err := a.pin.NewPass(ctx, fmt.Sprintf("Enter new password for key %q:", key))
+	if err != nil {
+		return err
+	}
+
+	privEnc, err := sealPrivKey(pass, priv, params)
+	if err != nil {
+		return err
+	}
+
+	queryUpdate := `UPDATE keys SET private = ?, updated = ? WHERE id = ?`
+	if _, err := tx.Exec(queryUpdate, privEnc, timeNow().Unix(), kid); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(a.w, "changed password for key %q\n", key)
+	if calibrated {
+		fmt.Fprintf(a.w, "calibrated KDF: %s\n", params)
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestCmdPasswd(t *testing.T) {
+	ctx := context.Background()
+	app, _ := testNewApp(t, &testPinentry{})
+
+	err := app.run(ctx, []string{"passwd"})
+	if !errors.Is(err, errUsage) {
+		t.Errorf("passwd err = %v; want %v", err, errUsage)
+	}
+
+	err = app.run(ctx, []string{"passwd", "test-1:test-1"})
+	if !errors.Is(err, errUsage) {
+		t.Errorf("passwd err = %v; want %v", err, errUsage)
+	}
+
+	err = app.run(ctx, []string{"passwd", "INVALID"})
+	if !errors.Is(err, errIdentifier) {
+		t.Errorf("passwd err = %v; want %v", err, errIdentifier)
+	}
+}
+
+func TestCmdPasswdKey(t *testing.T) {
+	ctx := context.Background()
+	pin := &testPinentry{pass: "pass-1", newPass: "pass-new"}
+	app, out := testNewApp(t, pin)
+
+	var before string
+	if err := app.st.QueryRow(`SELECT data FROM pass WHERE id = 1`).Scan(&before); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	err := app.run(ctx, []string{"passwd", "test-1"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if want := fmt.Sprintf("changed password for key %q\n", "test-1"); out.String() != want {
+		t.Errorf("passwd out = %q; want %q", out.String(), want)
+	}
+
+	var after string
+	if err := app.st.QueryRow(`SELECT data FROM pass WHERE id = 1`).Scan(&after); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if before != after {
+		t.Errorf("pass data changed: %q; want %q", after, before)
+	}
+
+	out.Reset()
+	pin.pass = "pass-new"
+	err = app.run(ctx, []string{"show", "test-1:test-1:pass"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "pass-1\n"; out.String() != want {
+		t.Errorf("show (pass) out = %q; want %q", out.String(), want)
+	}
+}
+
+func TestCmdPasswdKDF(t *testing.T) {
+	ctx := context.Background()
+	pin := &testPinentry{pass: "pass-1", newPass: "pass-new"}
+	app, out := testNewApp(t, pin)
+
+	queryPriv := `SELECT private FROM keys WHERE name = 'test-1'`
+
+	// Legacy keys keep their parameters, now stored in an envelope
+	err := app.run(ctx, []string{"passwd", "test-1"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var priv string
+	if err := app.st.QueryRow(queryPriv).Scan(&priv); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !isEnvelope(priv) {
+		t.Errorf("keys.private = %q; want envelope", priv)
+	}
+	if p, err := privKeyParams(priv); err != nil || p != legacyKDF {
+		t.Errorf("KDF = %v, %v; want %v", p, err, legacyKDF)
+	}
+
+	out.Reset()
+	pin.pass = "pass-new"
+	err = app.run(ctx, []string{"passwd", "-kdf-time", "2", "test-1"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := app.st.QueryRow(queryPriv).Scan(&priv); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := kdfParams{Time: 2, Memory: legacyKDF.Memory, Threads: legacyKDF.Threads}
+	if p, err := privKeyParams(priv); err != nil || p != want {
+		t.Errorf("KDF = %v, %v; want %v", p, err, want)
+	}
+
+	err = app.run(ctx, []string{"show", "test-1:test-1:pass"})
+	if err != nil {
+		t.Errorf("could not unlock key: %v", err)
+	}
+}
+
+func TestCmdPasswdKeyFail(t *testing.T) {
+	ctx := context.Background()
+	app, _ := testNewApp(t, &testPinentry{})
+
+	err := app.run(ctx, []string{"passwd", "test-none"})
+	if want := fmt.Errorf("non-existent key %q", "test-none"); !reflect.DeepEqual(err, want) {
+		t.Errorf("passwd err = %v; want %v", err, want)
+	}
+}
+
+func TestCmdPasswdPassFail(t *testing.T) {
+	ctx := context.Background()
+	pin := &testPinentry{pass: "incorrect"}
+	app, _ := testNewApp(t, pin)
+
+	err := app.run(ctx, []string{"passwd", "test-1"})
+	if !errors.Is(err, errTestPinentryVerify) {
+		t.Errorf("passwd err = %v; want %v", err, errTestPinentryVerify)
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+var errCancelled = errors.New("cancelled by user")
+
+func (a *app) cmdRm(ctx context.Context, args []string) error {
+	fs := newFlagSet("rm")
+	recursive := fs.Bool("r", false, "remove a key along with all of its passes")
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	args = fs.Args()
+
+	if len(args) != 1 {
+		return errUsage
+	}
+
+	key, name, typ, err := parseIdentifier(args[0])
+	if err != nil {
+		return err
+	}
+
+	if key != "" && name != "" && typ != "" {
+		return a.cmdRmPass(ctx, key, name, typ)
+	}
+	if key != "" && name != "" && typ == "" {
+		return a.cmdRmName(ctx, key, name)
+	}
+	if key != "" && name == "" && typ == "" {
+		return a.cmdRmKey(ctx, key, *recursive)
+	}
+
+	return errUsage
+}
+
+func (a *app) confirm(ctx context.Context, prompt string) error {
+	ok, err := a.pin.Confirm(ctx, prompt)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errCancelled
+	}
+	return nil
+}
+
+func (a *app) cmdRmKey(ctx context.Context, key string, recursive bool) error {
+	tx, err := a.st.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var kid int64
+	queryKey := `SELECT id FROM keys WHERE name = ? AND trashed IS NULL LIMIT 1`
+	err = tx.QueryRow(queryKey, key).Scan(&kid)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("non-existent key %q", key)
+	}
+	if err != nil {
+		return err
+	}
+
+	var count int
+	queryCount := `SELECT COUNT(*) FROM pass WHERE key_id = ? AND trashed IS NULL`
+	err = tx.QueryRow(queryCount, kid).Scan(&count)
+	if err != nil {
+		return err
+	}
+	if count > 0 && !recursive {
+		return fmt.Errorf("key %q is not empty", key)
+	}
+
+	prompt := fmt.Sprintf("Move key %q to trash?", key)
+	if count > 0 {
+		prompt = fmt.Sprintf("Move key %q and its %d passes to trash?", key, count)
+	}
+	if err := a.confirm(ctx, prompt); err != nil {
+		return err
+	}
+
+	// Passes trashed along with their key are restored with it
+	now := timeNow().Unix()
+	queryTrashPass := `UPDATE pass SET trashed = ? WHERE key_id = ? AND trashed IS NULL`
+	if _, err := tx.Exec(queryTrashPass, now, kid); err != nil {
+		return err
+	}
+
+	queryTrashKey := `UPDATE keys SET trashed = ? WHERE id = ?`
+	if _, err := tx.Exec(queryTrashKey, now, kid); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(a.w, "moved key %q to trash\n", key)
+	return tx.Commit()
+}
+
+func (a *app) cmdRmName(ctx context.Context, key, name string) error {
+	fullName := fmt.Sprintf("%s:%s", key, name)
+
+	tx, err := a.st.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	k, err := queryKey(tx, key)
+	if err != nil {
+		return err
+	}
+	if err := a.openIndex(ctx, &k); err != nil {
+		return err
+	}
+	idxName, _, err := k.passIndex(name, "")
+	if err != nil {
+		return err
+	}
+
+	var count int
+	queryCount := `SELECT COUNT(*) FROM pass WHERE key_id = ? AND name = ? AND trashed IS NULL`
+	err = tx.QueryRow(queryCount, k.id, idxName).Scan(&count)
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return fmt.Errorf("non-existent pass %q", fullName)
+	}
+
+	if err := a.confirm(ctx, fmt.Sprintf("Move %d passes under %q to trash?", count, fullName)); err != nil {
+		return err
+	}
+
+	queryTrash := `UPDATE pass SET trashed = ? WHERE key_id = ? AND name = ? AND trashed IS NULL`
+	if _, err := tx.Exec(queryTrash, timeNow().Unix(), k.id, idxName); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(a.w, "moved pass %q to trash\n", fullName)
+	return tx.Commit()
+}
+
+func (a *app) cmdRmPass(ctx context.Context, key, name, typ string) error {
+	fullName := fmt.Sprintf("%s:%s:%s", key, name, typ)
+
+	tx, err := a.st.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	k, err := queryKey(tx, key)
+	if err != nil {
+		return err
+	}
+	if err := a.openIndex(ctx, &k); err != nil {
+		return err
+	}
+	p, err := queryPass(tx, k, name, typ)
+	if err != nil {
+		return err
+	}
+
+	if err := a.confirm(ctx, fmt.Sprintf("Move pass %q to trash?", fullName)); err != nil {
+		return err
+	}
+
+	queryTrash := `UPDATE pass SET trashed = ? WHERE id = ?`
+	if _, err := tx.Exec(queryTrash, timeNow().Unix(), p.id); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(a.w, "moved pass %q to trash\n", fullName)
+	return tx.Commit()
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestCmdRm(t *testing.T) {
+	ctx := context.Background()
+	app, _ := testNewApp(t, &testPinentry{confirm: true})
+
+	err := app.run(ctx, []string{"rm"})
+	if !errors.Is(err, errUsage) {
+		t.Errorf("rm err = %v; want %v", err, errUsage)
+	}
+
+	err = app.run(ctx, []string{"rm", "-invalid", "test-1"})
+	if !errors.Is(err, errUsage) {
+		t.Errorf("rm err = %v; want %v", err, errUsage)
+	}
+
+	err = app.run(ctx, []string{"rm", "INVALID"})
+	if !errors.Is(err, errIdentifier) {
+		t.Errorf("rm err = %v; want %v", err, errIdentifier)
+	}
+}
+
+func TestCmdRmKey(t *testing.T) {
+	ctx := context.Background()
+	app, out := testNewApp(t, &testPinentry{confirm: true})
+
+	if _, err := app.st.Exec(`DELETE FROM pass WHERE key_id = 2`); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	err := app.run(ctx, []string{"rm", "test-2"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if want := fmt.Sprintf("moved key %q to trash\n", "test-2"); out.String() != want {
+		t.Errorf("rm (key) out = %q; want %q", out.String(), want)
+	}
+
+	var exists bool
+	err = app.st.QueryRow(`SELECT EXISTS(SELECT 1 FROM keys WHERE name = 'test-2' AND trashed IS NULL)`).Scan(&exists)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if exists {
+		t.Errorf("key %q still exists", "test-2")
+	}
+}
+
+func TestCmdRmKeyRecursive(t *testing.T) {
+	ctx := context.Background()
+	app, _ := testNewApp(t, &testPinentry{confirm: true})
+
+	err := app.run(ctx, []string{"rm", "-r", "test-1"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var count int
+	err = app.st.QueryRow(`SELECT COUNT(*) FROM pass WHERE key_id = 1 AND trashed IS NULL`).Scan(&count)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if count != 0 {
+		t.Errorf("pass count = %d; want %d", count, 0)
+	}
+}
+
+func TestCmdRmKeyNotEmptyFail(t *testing.T) {
+	ctx := context.Background()
+	app, _ := testNewApp(t, &testPinentry{confirm: true})
+
+	err := app.run(ctx, []string{"rm", "test-1"})
+	if want := fmt.Errorf("key %q is not empty", "test-1"); !reflect.DeepEqual(err, want) {
+		t.Errorf("rm (key) err = %v; want %v", err, want)
+	}
+}
+
+func TestCmdRmKeyKeyFail(t *testing.T) {
+	ctx := context.Background()
+	app, _ := testNewApp(t, &testPinentry{confirm: true})
+
+	err := app.run(ctx, []string{"rm", "test-none"})
+	if want := fmt.Errorf("non-existent key %q", "test-none"); !reflect.DeepEqual(err, want) {
+		t.Errorf("rm (key) err = %v; want %v", err, want)
+	}
+}
+
+func TestCmdRmName(t *testing.T) {
+	ctx := context.Background()
+	app, out := testNewApp(t, &testPinentry{confirm: true})
+
+	err := app.run(ctx, []string{"rm", "test-1:test-1"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if want := fmt.Sprintf("moved pass %q to trash\n", "test-1:test-1"); out.String() != want {
+		t.Errorf("rm (name) out = %q; want %q", out.String(), want)
+	}
+}
+
+func TestCmdRmNameNameFail(t *testing.T) {
+	ctx := context.Background()
+	app, _ := testNewApp(t, &testPinentry{confirm: true})
+
+	err := app.run(ctx, []string{"rm", "test-1:none"})
+	if want := fmt.Errorf("non-existent pass %q", "test-1:none"); !reflect.DeepEqual(err, want) {
+		t.Errorf("rm (name) err = %v; want %v", err, want)
+	}
+}
+
+func TestCmdRmPass(t *testing.T) {
+	ctx := context.Background()
+	app, out := testNewApp(t, &testPinentry{confirm: true})
+
+	err := app.run(ctx, []string{"rm", "test-1:test-1:pass"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if want := fmt.Sprintf("moved pass %q to trash\n", "test-1:test-1:pass"); out.String() != want {
+		t.Errorf("rm (pass) out = %q; want %q", out.String(), want)
+	}
+
+	err = app.run(ctx, []string{"show", "test-1:test-1"})
+	if want := fmt.Errorf("non-existent pass %q", "test-1:test-1"); !reflect.DeepEqual(err, want) {
+		t.Errorf("show (name) err = %v; want %v", err, want)
+	}
+}
+
+func TestCmdRmPassPassFail(t *testing.T) {
+	ctx := context.Background()
+	app, _ := testNewApp(t, &testPinentry{confirm: true})
+
+	err := app.run(ctx, []string{"rm", "test-1:test-1:none"})
+	if want := fmt.Errorf("non-existent pass %q", "test-1:test-1:none"); !reflect.DeepEqual(err, want) {
+		t.Errorf("rm (pass) err = %v; want %v", err, want)
+	}
+}
+
+func TestCmdRmPassCancel(t *testing.T) {
+	ctx := context.Background()
+	app, _ := testNewApp(t, &testPinentry{confirm: false})
+
+	err := app.run(ctx, []string{"rm", "test-1:test-1:pass"})
+	if !errors.Is(err, errCancelled) {
+		t.Errorf("rm (pass) err = %v; want %v", err, errCancelled)
+	}
+
+	var exists bool
+	err = app.st.QueryRow(`SELECT EXISTS(SELECT 1 FROM pass WHERE id = 1)`).Scan(&exists)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !exists {
+		t.Errorf("pass %q was removed", "test-1:test-1:pass")
+	}
+}
+
+func TestCmdRmPassPinFail(t *testing.T) {
+	ctx := context.Background()
+	pin := &testPinentry{err: errors.New("testing error")}
+	app, _ := testNewApp(t, pin)
+
+	err := app.run(ctx, []string{"rm", "test-1:test-1:pass"})
+	if !errors.Is(err, pin.err) {
+		t.Errorf("rm (pass) err = %v; want %v", err, pin.err)
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+
+	"golang.org/x/crypto/nacl/box"
+)
+
+func (a *app) cmdRotate(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+
+	key, name, typ, err := parseIdentifier(args[0])
+	if err != nil {
+		return err
+	}
+	if name != "" || typ != "" {
+		return errUsage
+	}
+
+	tx, err := a.st.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	k, err := queryKey(tx, key)
+	if err != nil {
+		return err
+	}
+
+	oldPub, err := decodePubKey(k.pub)
+	if err != nil {
+		return err
+	}
+
+	oldPriv, err := a.unlock(ctx, &k)
+	if err != nil {
+		return err
+	}
+	params, err := privKeyParams(k.priv)
+	if err != nil {
+		return err
+	}
+
+	// Trashed passes are rotated too, so that they can still be restored
+	pass, err := queryPasses(tx, k, "")
+	if err != nil {
+		return err
+	}
+	if err := namePasses(k, pass); err != nil {
+		return err
+	}
+
+	newPub, newPriv, err := box.GenerateKey(rand.Reader)
+	if err != nil {
+		return err
+	}
+
+	passwd, err := a.pin.NewPass(ctx, fmt.Sprintf("Enter new password for key %q:", key))
+	if err != nil {
+		return err
+	}
+
+	privEnc, err := sealPrivKey(passwd, newPriv, params)
+	if err != nil {
+		return err
+	}
+
+	newPubEnc := base64.RawStdEncoding.EncodeToString(newPub[:])
+
+	// A private index is derived from the private key, so it is rebuilt too
+	newKey := k
+	newKey.pub, newKey.unlocked = newPubEnc, newPriv
+
+	queryUpdatePass := `UPDATE pass SET name = ?, type = ?, sealed_name = ?, data = ? WHERE id = ?`
+	for i, p := range pass {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		fullName := fmt.Sprintf("%s:%s:%s", key, p.name, p.typ)
+
+		dec, err := openPass(oldPub, oldPriv, key, p.idxName, p.idxType, p.data)
+		if err != nil {
+			return fmt.Errorf("could not decrypt pass %q: %w", fullName, err)
+		}
+
+		idxName, idxType, err := newKey.passIndex(p.name, p.typ)
+		if err != nil {
+			return err
+		}
+		enc, err := sealPass(newPub, key, idxName, idxType, dec, k.padding)
+		if err != nil {
+			return err
+		}
+		sealedName, err := newKey.sealName(p.name, p.typ, idxName, idxType)
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(queryUpdatePass, idxName, idxType, sealedName, enc, p.id); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(a.w, "[%d/%d] re-encrypted pass %q\n", i+1, len(pass), fullName)
+	}
+
+	queryUpdateKey := `UPDATE keys SET public = ?, private = ?, updated = ? WHERE id = ?`
+	if _, err := tx.Exec(queryUpdateKey, newPubEnc, privEnc, timeNow().Unix(), k.id); err != nil {
+		return err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(a.w, "rotated key %q: %s\n", key, newPubEnc)
+	return tx.Commit()
+}
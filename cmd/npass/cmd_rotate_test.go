@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCmdRotate(t *testing.T) {
+	ctx := context.Background()
+	app, _ := testNewApp(t, &testPinentry{})
+
+	err := app.run(ctx, []string{"rotate"})
+	if !errors.Is(err, errUsage) {
+		t.Errorf("rotate err = %v; want %v", err, errUsage)
+	}
+
+	err = app.run(ctx, []string{"rotate", "test-1:test-1:pass"})
+	if !errors.Is(err, errUsage) {
+		t.Errorf("rotate err = %v; want %v", err, errUsage)
+	}
+
+	err = app.run(ctx, []string{"rotate", "INVALID"})
+	if !errors.Is(err, errIdentifier) {
+		t.Errorf("rotate err = %v; want %v", err, errIdentifier)
+	}
+}
+
+func TestCmdRotateKey(t *testing.T) {
+	ctx := context.Background()
+	pin := &testPinentry{pass: "pass-1", newPass: "pass-new"}
+	app, out := testNewApp(t, pin)
+
+	err := app.run(ctx, []string{"rotate", "test-1"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := fmt.Sprintf("[1/1] re-encrypted pass %q\nrotated key %q: ", "test-1:test-1:pass", "test-1")
+	if !strings.HasPrefix(out.String(), want) {
+		t.Errorf("rotate out = %q; want prefix %q", out.String(), want)
+	}
+
+	var pub string
+	if err := app.st.QueryRow(`SELECT public FROM keys WHERE id = 1`).Scan(&pub); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if pub == "5M60s3mDoFQJQOkZxyn0nOo2VuKzdUJZU60j3Ymkny4" {
+		t.Errorf("public key was not changed")
+	}
+
+	out.Reset()
+	pin.pass = "pass-new"
+	err = app.run(ctx, []string{"show", "test-1:test-1:pass"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "pass-1\n"; out.String() != want {
+		t.Errorf("show (pass) out = %q; want %q", out.String(), want)
+	}
+}
+
+func TestCmdRotateKeyFail(t *testing.T) {
+	ctx := context.Background()
+	app, _ := testNewApp(t, &testPinentry{})
+
+	err := app.run(ctx, []string{"rotate", "test-none"})
+	if want := fmt.Errorf("non-existent key %q", "test-none"); !reflect.DeepEqual(err, want) {
+		t.Errorf("rotate err = %v; want %v", err, want)
+	}
+}
+
+func TestCmdRotatePassFail(t *testing.T) {
+	ctx := context.Background()
+	app, _ := testNewApp(t, &testPinentry{pass: "incorrect"})
+
+	err := app.run(ctx, []string{"rotate", "test-1"})
+	if !errors.Is(err, errTestPinentryVerify) {
+		t.Errorf("rotate err = %v; want %v", err, errTestPinentryVerify)
+	}
+}
+
+type cancelPinentry struct {
+	testPinentry
+	cancel context.CancelFunc
+}
+
+func (cp cancelPinentry) NewPass(ctx context.Context, prompt string) (string, error) {
+	cp.cancel()
+	return cp.testPinentry.NewPass(ctx, prompt)
+}
+
+func TestCmdRotateInterrupt(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	pin := cancelPinentry{testPinentry{pass: "pass-1"}, cancel}
+	app, _ := testNewApp(t, pin)
+
+	err := app.run(ctx, []string{"rotate", "test-1"})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("rotate err = %v; want %v", err, context.Canceled)
+	}
+
+	var pub, data string
+	err = app.st.QueryRow(`SELECT public, data FROM keys JOIN pass ON pass.key_id = keys.id WHERE keys.id = 1`).Scan(&pub, &data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "5M60s3mDoFQJQOkZxyn0nOo2VuKzdUJZU60j3Ymkny4"; pub != want {
+		t.Errorf("public = %q; want %q", pub, want)
+	}
+	if want := "LUgYJuptQvsht2iIrKJ9tOnAPyG4V3XYKsCyda59/ly5iYCWejMYBQyN5lt0Nf6G4TelWpAAQTKDhrrfQPD3hxHUbxXsYuVdkg"; data != want {
+		t.Errorf("data = %q; want %q", data, want)
+	}
+}
@@ -3,297 +3,425 @@ package main
 import (
 	"context"
 	"database/sql"
-	"encoding/base64"
-	"errors"
 	"fmt"
+	"io"
+	"sort"
 	"strings"
-
-	"golang.org/x/crypto/nacl/box"
-	"golang.org/x/crypto/nacl/secretbox"
+	"time"
 )
 
 func (a *app) cmdShow(ctx context.Context, args []string) error {
-	if len(args) > 1 {
+	fs := newFlagSet("show")
+	long := fs.Bool("l", false, "show creation, modification and access times")
+	sortBy := fs.String("sort", "name", "sort listings by name, created, updated or accessed")
+	olderThan := fs.Int("older-than", 0, "only list passes not modified in this many days")
+	if err := fs.Parse(args); err != nil {
 		return errUsage
 	}
+	args = fs.Args()
 
 	var (
 		key, name, typ string
 		err            error
 	)
-	if len(args) == 1 {
+	if len(args) >= 1 {
 		key, name, typ, err = parseIdentifier(args[0])
+		// Extra arguments select an operation on a single pass
+		if len(args) > 1 && (err != nil || typ == "") {
+			return errUsage
+		}
 		if err != nil {
 			return err
 		}
 	}
 
+	order, ok := listOrders[*sortBy]
+	if !ok || *olderThan < 0 {
+		return errUsage
+	}
+	opts := listOpts{long: *long, order: order}
+	if *olderThan > 0 {
+		opts.before = timeNow().AddDate(0, 0, -*olderThan).Unix()
+	}
+
 	if key == "" && name == "" && typ == "" {
-		err = a.cmdShowAll(ctx)
+		err = a.cmdShowAll(ctx, opts)
 	} else if key != "" && name == "" && typ == "" {
-		err = a.cmdShowKey(ctx, key)
+		err = a.cmdShowKey(ctx, key, opts)
 	} else if key != "" && name != "" && typ == "" {
-		err = a.cmdShowName(ctx, key, name)
+		err = a.cmdShowName(ctx, key, name, opts)
 	} else if key != "" && name != "" && typ != "" {
-		err = a.cmdShowPass(ctx, key, name, typ)
+		// Listing options make no sense for a single pass
+		if fs.NFlag() > 0 {
+			return errUsage
+		}
+		err = a.cmdShowPass(ctx, key, name, typ, args[1:])
 	}
 
 	return err
 }
 
-func (a *app) cmdShowAll(ctx context.Context) error {
-	tx, err := a.st.BeginTx(ctx, nil)
-	if err != nil {
-		return err
+// listOpts controls how show lists passes.
+type listOpts struct {
+	long bool
+	// The time passes are sorted by, or nil to sort them by name.
+	order func(listTimes) sql.NullInt64
+	// If non-zero, only passes last modified before this time are listed.
+	before int64
+}
+
+// Orderings of pass listings, by name or by one of their times. Ties are
+// sorted by name, and unknown times sort first.
+var listOrders = map[string]func(listTimes) sql.NullInt64{
+	"name":     nil,
+	"created":  func(lt listTimes) sql.NullInt64 { return lt.created },
+	"updated":  func(lt listTimes) sql.NullInt64 { return lt.updated },
+	"accessed": func(lt listTimes) sql.NullInt64 { return lt.accessed },
+}
+
+// where returns the condition selecting the passes to list. Trashed passes
+// are never listed.
+func (o listOpts) where() (string, []interface{}) {
+	if o.before == 0 {
+		return "trashed IS NULL", nil
 	}
-	defer func() { _ = tx.Rollback() }()
+	// Passes of unknown age are always old enough
+	return "trashed IS NULL AND (updated IS NULL OR updated < ?)", []interface{}{o.before}
+}
 
-	queryKeys := `SELECT id, name, public FROM keys ORDER BY name`
-	rows, err := tx.Query(queryKeys)
-	if err != nil {
-		return err
+func (o listOpts) less(p, q listPass) bool {
+	if o.order != nil {
+		tp, tq := o.order(p.times), o.order(q.times)
+		if tp != tq {
+			return !tp.Valid || (tq.Valid && tp.Int64 < tq.Int64)
+		}
 	}
-	defer rows.Close()
+	if p.name != q.name {
+		return p.name < q.name
+	}
+	return p.typ < q.typ
+}
 
-	type dbKey struct {
-		id        int64
-		name, pub string
+type listTimes struct {
+	created, updated, accessed sql.NullInt64
+}
+
+func (lt listTimes) String() string {
+	format := func(t sql.NullInt64) string {
+		if !t.Valid {
+			return "-"
+		}
+		return time.Unix(t.Int64, 0).Format("2006-01-02 15:04")
 	}
+	return fmt.Sprintf("created %s, updated %s, accessed %s",
+		format(lt.created), format(lt.updated), format(lt.accessed))
+}
 
-	var keys []dbKey
-	for rows.Next() {
-		var k dbKey
-		err := rows.Scan(&k.id, &k.name, &k.pub)
+// listPass is a pass as listed by show.
+type listPass struct {
+	name, typ string
+	times     listTimes
+}
+
+// listPasses returns the passes under k selected by opts, only those named
+// name if it is not empty. Keys with a private index are unlocked to
+// decrypt the names of their passes, if there are any.
+func (a *app) listPasses(ctx context.Context, tx *sql.Tx, k *dbKey, name string, opts listOpts) ([]listPass, error) {
+	where, whereArgs := opts.where()
+	query := `SELECT name, type, sealed_name, created, updated, accessed FROM pass WHERE key_id = ? AND ` + where
+	queryArgs := append([]interface{}{k.id}, whereArgs...)
+	if name != "" {
+		if err := a.openIndex(ctx, k); err != nil {
+			return nil, err
+		}
+		idxName, _, err := k.passIndex(name, "")
 		if err != nil {
-			return err
+			return nil, err
 		}
-		keys = append(keys, k)
+		query += ` AND name = ?`
+		queryArgs = append(queryArgs, idxName)
 	}
 
-	queryPass := `SELECT key_id, name, type FROM pass ORDER BY key_id, name, type`
-	rows, err = tx.Query(queryPass)
+	rows, err := tx.Query(query, queryArgs...)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer rows.Close()
 
-	type dbPass struct {
-		name, typ string
+	type row struct {
+		listPass
+		sealed sql.NullString
 	}
-
-	pass := make(map[int64][]dbPass)
+	var found []row
 	for rows.Next() {
-		var (
-			p   dbPass
-			kid int64
-		)
-		err := rows.Scan(&kid, &p.name, &p.typ)
+		var r row
+		err := rows.Scan(&r.name, &r.typ, &r.sealed, &r.times.created, &r.times.updated, &r.times.accessed)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		pass[kid] = append(pass[kid], p)
+		found = append(found, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(found) == 0 {
+		return nil, nil
 	}
 
-	for _, k := range keys {
-		fmt.Fprintf(a.w, "%s %s:\n", k.name, k.pub)
-		for _, p := range pass[k.id] {
-			fmt.Fprintf(a.w, "  %s: [%s]\n", p.name, p.typ)
+	if err := a.openIndex(ctx, k); err != nil {
+		return nil, err
+	}
+	pass := make([]listPass, len(found))
+	for i, r := range found {
+		pass[i] = r.listPass
+		pass[i].name, pass[i].typ, err = k.passName(r.name, r.typ, r.sealed)
+		if err != nil {
+			return nil, err
 		}
 	}
 
-	return tx.Commit()
+	sort.Slice(pass, func(i, j int) bool { return opts.less(pass[i], pass[j]) })
+	return pass, nil
 }
 
-func (a *app) cmdShowKey(ctx context.Context, key string) error {
+func (a *app) cmdShowAll(ctx context.Context, opts listOpts) error {
 	tx, err := a.st.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 	defer func() { _ = tx.Rollback() }()
 
-	var (
-		kid  int64
-		kpub string
-	)
-	queryKey := `SELECT id, public FROM keys WHERE name = ? LIMIT 1`
-	err = tx.QueryRow(queryKey, key).Scan(&kid, &kpub)
-	if errors.Is(err, sql.ErrNoRows) {
-		return fmt.Errorf("non-existent key %q", key)
-	}
-	if err != nil {
-		return err
-	}
-
-	queryPass := `SELECT name, type FROM pass WHERE key_id = ? ORDER BY name, type`
-	rows, err := tx.Query(queryPass, kid)
+	queryKeys := `SELECT id, name, public, private, private_index, created, updated, accessed FROM keys
+	WHERE trashed IS NULL ORDER BY name`
+	rows, err := tx.Query(queryKeys)
 	if err != nil {
 		return err
 	}
 	defer rows.Close()
 
-	fmt.Fprintf(a.w, "%s %s:\n", key, kpub)
+	type listKey struct {
+		dbKey
+		times listTimes
+	}
 
+	var keys []listKey
 	for rows.Next() {
-		var name, typ string
-		err := rows.Scan(&name, &typ)
+		var k listKey
+		err := rows.Scan(&k.id, &k.name, &k.pub, &k.priv, &k.privateIndex,
+			&k.times.created, &k.times.updated, &k.times.accessed)
 		if err != nil {
 			return err
 		}
-		fmt.Fprintf(a.w, "  %s: [%s]\n", name, typ)
+		keys = append(keys, k)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	for _, k := range keys {
+		pass, err := a.listPasses(ctx, tx, &k.dbKey, "", opts)
+		if err != nil {
+			return err
+		}
+
+		// Keys without any old passes are not interesting
+		if opts.before != 0 && len(pass) == 0 {
+			continue
+		}
+
+		if opts.long {
+			fmt.Fprintf(a.w, "%s %s: %s\n", k.name, k.pub, k.times)
+		} else {
+			fmt.Fprintf(a.w, "%s %s:\n", k.name, k.pub)
+		}
+		for _, p := range pass {
+			if opts.long {
+				fmt.Fprintf(a.w, "  %s: [%s] %s\n", p.name, p.typ, p.times)
+			} else {
+				fmt.Fprintf(a.w, "  %s: [%s]\n", p.name, p.typ)
+			}
+		}
 	}
 
 	return tx.Commit()
 }
 
-func (a *app) cmdShowName(ctx context.Context, key, name string) error {
+func (a *app) cmdShowKey(ctx context.Context, key string, opts listOpts) error {
 	tx, err := a.st.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 	defer func() { _ = tx.Rollback() }()
 
-	var kid int64
-	queryKey := `SELECT id FROM keys WHERE name = ? LIMIT 1`
-	err = tx.QueryRow(queryKey, key).Scan(&kid)
-	if errors.Is(err, sql.ErrNoRows) {
-		return fmt.Errorf("non-existent key %q", key)
-	}
+	k, err := queryKey(tx, key)
 	if err != nil {
 		return err
 	}
 
-	queryPass := `SELECT type FROM pass WHERE key_id = ? AND name = ? ORDER BY type`
-	rows, err := tx.Query(queryPass, kid, name)
+	var ktimes listTimes
+	queryTimes := `SELECT created, updated, accessed FROM keys WHERE id = ?`
+	err = tx.QueryRow(queryTimes, k.id).Scan(&ktimes.created, &ktimes.updated, &ktimes.accessed)
 	if err != nil {
 		return err
 	}
-	defer rows.Close()
 
-	exists := false
-	for rows.Next() {
-		exists = true
+	pass, err := a.listPasses(ctx, tx, &k, "", opts)
+	if err != nil {
+		return err
+	}
 
-		var typ string
-		err := rows.Scan(&typ)
-		if err != nil {
-			return err
-		}
-		fmt.Fprintf(a.w, "%s:%s:%s\n", key, name, typ)
+	if opts.long {
+		fmt.Fprintf(a.w, "%s %s: %s\n", key, k.pub, ktimes)
+	} else {
+		fmt.Fprintf(a.w, "%s %s:\n", key, k.pub)
 	}
 
-	if !exists {
-		return fmt.Errorf("non-existent pass %q", fmt.Sprintf("%s:%s", key, name))
+	for _, p := range pass {
+		if opts.long {
+			fmt.Fprintf(a.w, "  %s: [%s] %s\n", p.name, p.typ, p.times)
+		} else {
+			fmt.Fprintf(a.w, "  %s: [%s]\n", p.name, p.typ)
+		}
 	}
 
 	return tx.Commit()
 }
 
-func (a *app) cmdShowPass(ctx context.Context, key, name, typ string) error {
+func (a *app) cmdShowName(ctx context.Context, key, name string, opts listOpts) error {
 	tx, err := a.st.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 	defer func() { _ = tx.Rollback() }()
 
-	var (
-		kid             int64
-		keyPub, keyPriv string
-	)
-	queryKey := `SELECT id, public, private FROM keys WHERE name = ? LIMIT 1`
-	err = tx.QueryRow(queryKey, key).Scan(&kid, &keyPub, &keyPriv)
-	if errors.Is(err, sql.ErrNoRows) {
-		return fmt.Errorf("non-existent key %q", key)
-	}
+	k, err := queryKey(tx, key)
 	if err != nil {
 		return err
 	}
 
-	keyPubRaw, err := base64.RawStdEncoding.DecodeString(keyPub)
+	if err := a.checkName(ctx, tx, &k, name); err != nil {
+		return err
+	}
+
+	pass, err := a.listPasses(ctx, tx, &k, name, opts)
 	if err != nil {
 		return err
 	}
-	keyPrivRaw, err := base64.RawStdEncoding.DecodeString(keyPriv)
+
+	for _, p := range pass {
+		if opts.long {
+			fmt.Fprintf(a.w, "%s:%s:%s %s\n", key, name, p.typ, p.times)
+		} else {
+			fmt.Fprintf(a.w, "%s:%s:%s\n", key, name, p.typ)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// checkName returns an error unless k has a pass named name, of any type.
+func (a *app) checkName(ctx context.Context, tx *sql.Tx, k *dbKey, name string) error {
+	if err := a.openIndex(ctx, k); err != nil {
+		return err
+	}
+	idxName, _, err := k.passIndex(name, "")
 	if err != nil {
 		return err
 	}
 
 	var exists bool
-	queryNameExists := `SELECT EXISTS(SELECT 1 FROM pass WHERE key_id = ? AND name = ?)`
-	err = tx.QueryRow(queryNameExists, kid, name).Scan(&exists)
+	queryNameExists := `SELECT EXISTS(SELECT 1 FROM pass WHERE key_id = ? AND name = ? AND trashed IS NULL)`
+	err = tx.QueryRow(queryNameExists, k.id, idxName).Scan(&exists)
 	if err != nil {
 		return err
 	}
 	if !exists {
-		return fmt.Errorf("non-existent pass %q", fmt.Sprintf("%s:%s", key, name))
+		return fmt.Errorf("non-existent pass %q", fmt.Sprintf("%s:%s", k.name, name))
 	}
+	return nil
+}
 
-	pass, err := newPass(typ)
+func (a *app) cmdShowPass(ctx context.Context, key, name, typ string, op []string) error {
+	tx, err := a.st.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
+	defer func() { _ = tx.Rollback() }()
 
-	var passData []byte
-	queryPass := `SELECT data FROM pass WHERE key_id = ? AND name = ? AND type = ? LIMIT 1`
-	err = tx.QueryRow(queryPass, kid, name, typ).Scan(&passData)
-	if errors.Is(err, sql.ErrNoRows) {
-		return fmt.Errorf("non-existent pass %s",
-			fmt.Sprintf("%s:%s:%s", key, name, typ))
-	}
+	k, err := queryKey(tx, key)
 	if err != nil {
 		return err
 	}
 
-	passDataDec, err := base64.RawStdEncoding.DecodeString(string(passData))
-	if err != nil {
+	if err := a.checkName(ctx, tx, &k, name); err != nil {
 		return err
 	}
 
-	salt := make([]byte, 16)
-	keyPrivRaw = keyPrivRaw[copy(salt, keyPrivRaw):]
-
-	var keyPrivDec []byte
-	_, err = a.pin.AskPass(ctx, fmt.Sprintf("Enter password for key %q:", key),
-		func(pass string) bool {
-			pkey := passKey(pass, salt)
-			var keyArr [32]byte
-			copy(keyArr[:], pkey)
-
-			dec, ok := secretbox.Open(nil, keyPrivRaw, &[24]byte{}, &keyArr)
-			if !ok {
-				return false
-			}
+	if _, err := newPass(typ); err != nil {
+		return err
+	}
 
-			keyPrivDec = dec
-			return true
-		},
-	)
+	p, err := queryPass(tx, k, name, typ)
 	if err != nil {
 		return err
 	}
 
-	var keyPubArr, keyPrivArr [32]byte
-	copy(keyPubArr[:], keyPubRaw)
-	copy(keyPrivArr[:], keyPrivDec)
+	pass, err := a.unlockPass(ctx, tx, k, p)
+	if err != nil {
+		return err
+	}
 
-	passDec, ok := box.OpenAnonymous(nil, passDataDec, &keyPubArr, &keyPrivArr)
-	if !ok {
-		return fmt.Errorf("decryption error")
+	write, err := showPass(pass, typ, op)
+	if err != nil {
+		return err
 	}
 
-	if err := pass.UnmarshalText(passDec); err != nil {
+	if ps, ok := pass.(passStateful); ok && ps.modified() {
+		if err := updatePassState(tx, k, p, pass); err != nil {
+			return err
+		}
+	}
+	if err := accessPass(tx, k, p); err != nil {
 		return err
 	}
 
-	out, err := pass.printPass()
-	if err != nil {
+	if err := tx.Commit(); err != nil {
 		return err
 	}
 
-	split := strings.SplitN(out, ":", 4)
-	if len(split) != 4 || split[0] != key || split[1] != name || split[2] != typ {
-		return fmt.Errorf("decryption error")
+	return write(a.w)
+}
+
+// showPass returns a function writing out the value of pass, or the result of
+// the operation op on it, to be called once any change to its state is
+// stored.
+func showPass(pass passType, typ string, op []string) (func(io.Writer) error, error) {
+	var (
+		out string
+		err error
+	)
+	if len(op) == 0 {
+		if pw, ok := pass.(passWriter); ok {
+			return pw.writePass, nil
+		}
+		out, err = pass.printPass()
+	} else if po, ok := pass.(passOperator); ok {
+		out, err = po.operate(op[0], op[1:])
+	} else {
+		err = fmt.Errorf("pass type %q does not support operations", typ)
+	}
+	if err != nil {
+		return nil, err
 	}
-	fmt.Fprintln(a.w, split[3])
 
-	return tx.Commit()
+	// Output already ending in a newline is printed exactly, without
+	// doubling it.
+	if !strings.HasSuffix(out, "\n") {
+		out += "\n"
+	}
+	return func(w io.Writer) error {
+		_, err := io.WriteString(w, out)
+		return err
+	}, nil
 }
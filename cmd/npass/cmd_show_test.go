@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"reflect"
 	"testing"
+	"time"
 )
 
 func TestCmdShow(t *testing.T) {
@@ -165,3 +166,149 @@ func TestCmdShowPassPinFail(t *testing.T) {
 		t.Fatalf("show (pass) err = %v; want %v", err, pin.err)
 	}
 }
+
+func TestCmdShowPassSwappedFail(t *testing.T) {
+	ctx := context.Background()
+	pin := &testPinentry{pass: "pass-1"}
+	app, _ := testNewApp(t, pin)
+
+	for _, id := range []string{"test-1:test-a:pass", "test-1:test-b:pass"} {
+		if err := app.run(ctx, []string{"new", id}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	querySwap := `UPDATE pass SET data = (SELECT data FROM pass WHERE name = 'test-a')
+	WHERE name = 'test-b'`
+	if _, err := app.st.Exec(querySwap); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	err := app.run(ctx, []string{"show", "test-1:test-b:pass"})
+	if !errors.Is(err, errDecrypt) {
+		t.Errorf("show (pass) err = %v; want %v", err, errDecrypt)
+	}
+}
+
+func TestCmdShowTimes(t *testing.T) {
+	ctx := context.Background()
+	pin := &testPinentry{pass: "pass-1"}
+	app, out := testNewApp(t, pin)
+
+	t0 := time.Date(2020, 1, 1, 12, 0, 0, 0, time.Local)
+	t1 := t0.AddDate(0, 0, 40)
+	t2 := t1.AddDate(0, 0, 40)
+	format := func(t time.Time) string { return t.Format("2006-01-02 15:04") }
+
+	testTimeNow(t, t0)
+	if err := app.run(ctx, []string{"new", "test-1:old:pass"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	testTimeNow(t, t1)
+	if err := app.run(ctx, []string{"new", "test-1:new:pass"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	testTimeNow(t, t2)
+	if err := app.run(ctx, []string{"show", "test-1:old:pass"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		args []string
+		out  string
+	}{
+		{
+			args: []string{"show", "-l", "test-1:old"},
+			out:  fmt.Sprintf("test-1:old:pass created %s, updated %s, accessed %s\n", format(t0), format(t0), format(t2)),
+		},
+		{
+			args: []string{"show", "-l", "test-1"},
+			out: fmt.Sprintf("test-1 5M60s3mDoFQJQOkZxyn0nOo2VuKzdUJZU60j3Ymkny4: created -, updated -, accessed %s\n", format(t2)) +
+				fmt.Sprintf("  new: [pass] created %s, updated %s, accessed -\n", format(t1), format(t1)) +
+				fmt.Sprintf("  old: [pass] created %s, updated %s, accessed %s\n", format(t0), format(t0), format(t2)) +
+				"  test-1: [pass] created -, updated -, accessed -\n",
+		},
+		{
+			// Unknown times sort first
+			args: []string{"show", "-sort", "updated", "test-1"},
+			out:  "test-1 5M60s3mDoFQJQOkZxyn0nOo2VuKzdUJZU60j3Ymkny4:\n  test-1: [pass]\n  old: [pass]\n  new: [pass]\n",
+		},
+		{
+			args: []string{"show", "-older-than", "60"},
+			out:  "test-1 5M60s3mDoFQJQOkZxyn0nOo2VuKzdUJZU60j3Ymkny4:\n  old: [pass]\n  test-1: [pass]\ntest-2 VIFZnL4uDjJIwU61yIN0NV5ORYdehWU2PYeTwMwDvwc:\n  test-2: [pass]\n",
+		},
+		{
+			args: []string{"show", "-older-than", "50", "test-1:new"},
+			out:  "",
+		},
+	}
+
+	for _, tt := range tests {
+		out.Reset()
+		err := app.run(ctx, tt.args)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if out.String() != tt.out {
+			t.Errorf("%v out = %q; want %q", tt.args, out.String(), tt.out)
+		}
+	}
+}
+
+// Advancing the counter of a stateful pass is not a modification.
+func TestCmdShowStatefulTimes(t *testing.T) {
+	ctx := context.Background()
+	pin := &testPinentry{pass: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"}
+	app, out := testNewApp(t, pin)
+
+	t0 := time.Date(2020, 1, 1, 12, 0, 0, 0, time.Local)
+	t1 := t0.AddDate(0, 0, 40)
+	format := func(t time.Time) string { return t.Format("2006-01-02 15:04") }
+
+	testTimeNow(t, t0)
+	if err := app.run(ctx, []string{"new", "test-1:counter:hotp"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var before string
+	if err := app.st.QueryRow(`SELECT data FROM pass WHERE name = 'counter'`).Scan(&before); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	pin.pass = "pass-1"
+	testTimeNow(t, t1)
+	if err := app.run(ctx, []string{"show", "test-1:counter:hotp"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var after string
+	if err := app.st.QueryRow(`SELECT data FROM pass WHERE name = 'counter'`).Scan(&after); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if after == before {
+		t.Errorf("show did not write back the counter")
+	}
+
+	out.Reset()
+	if err := app.run(ctx, []string{"show", "-l", "test-1:counter"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := fmt.Sprintf("test-1:counter:hotp created %s, updated %s, accessed %s\n", format(t0), format(t0), format(t1))
+	if out.String() != want {
+		t.Errorf("show -l out = %q; want %q", out.String(), want)
+	}
+}
+
+func TestCmdShowListFail(t *testing.T) {
+	ctx := context.Background()
+	app, _ := testNewApp(t, &testPinentry{pass: "pass-1"})
+
+	for _, args := range [][]string{
+		{"show", "-sort", "invalid"},
+		{"show", "-older-than", "-1"},
+		{"show", "-l", "test-1:test-1:pass"},
+	} {
+		err := app.run(ctx, args)
+		if !errors.Is(err, errUsage) {
+			t.Errorf("%v err = %v; want %v", args, err, errUsage)
+		}
+	}
+}
@@ -0,0 +1,356 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+const sshKeyType = "ssh-key"
+
+var errAgentUnsupported = errors.New("operation not supported by npass agent")
+
+func (a *app) cmdSSHAgent(ctx context.Context, args []string) error {
+	fs := newFlagSet("ssh-agent")
+	sock := fs.String("a", "", "bind the agent to this unix socket")
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	args = fs.Args()
+
+	type ident struct{ key, name string }
+	var ids []ident
+	for _, arg := range args {
+		key, name, typ, err := parseIdentifier(arg)
+		if err != nil {
+			return err
+		}
+		if name == "" || typ != sshKeyType {
+			return errUsage
+		}
+		ids = append(ids, ident{key, name})
+	}
+
+	ag := &sshAgent{a: a, ctx: ctx}
+
+	tx, err := a.st.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// Keys are unlocked at most once while listing, and locked again before
+	// serving.
+	keys := make(map[string]*dbKey)
+	lockKeys := func() {
+		for _, k := range keys {
+			k.lock()
+		}
+	}
+	defer lockKeys()
+	getKey := func(key string) (*dbKey, error) {
+		if k, ok := keys[key]; ok {
+			return k, nil
+		}
+		k, err := queryKey(tx, key)
+		if err != nil {
+			return nil, err
+		}
+		keys[key] = &k
+		return &k, nil
+	}
+
+	if len(ids) == 0 {
+		// Passes under a private index are listed below, once decrypted
+		queryPass := `SELECT keys.name, pass.name FROM pass JOIN keys ON pass.key_id = keys.id
+	WHERE type = ? AND pass.trashed IS NULL AND keys.trashed IS NULL AND keys.private_index = 0`
+		rows, err := tx.Query(queryPass, sshKeyType)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var id ident
+			if err := rows.Scan(&id.key, &id.name); err != nil {
+				return err
+			}
+			ids = append(ids, id)
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		rows.Close()
+
+		queryKeys := `SELECT name FROM keys WHERE private_index != 0 AND trashed IS NULL`
+		rows, err = tx.Query(queryKeys)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		var private []string
+		for rows.Next() {
+			var key string
+			if err := rows.Scan(&key); err != nil {
+				return err
+			}
+			private = append(private, key)
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		rows.Close()
+
+		for _, key := range private {
+			k, err := getKey(key)
+			if err != nil {
+				return err
+			}
+			pass, err := queryPasses(tx, *k, `trashed IS NULL`)
+			if err != nil {
+				return err
+			}
+			if len(pass) == 0 {
+				continue
+			}
+			if err := a.openIndex(ctx, k); err != nil {
+				return err
+			}
+			if err := namePasses(*k, pass); err != nil {
+				return err
+			}
+			for _, p := range pass {
+				if p.typ == sshKeyType {
+					ids = append(ids, ident{key, p.name})
+				}
+			}
+		}
+
+		sort.Slice(ids, func(i, j int) bool {
+			if ids[i].key != ids[j].key {
+				return ids[i].key < ids[j].key
+			}
+			return ids[i].name < ids[j].name
+		})
+	}
+	if len(ids) == 0 {
+		return fmt.Errorf("no ssh keys found")
+	}
+
+	// Public keys are only kept inside the sealed payload, so each key is
+	// unlocked once to list them. Only the public keys are kept, and ssh keys
+	// are decrypted again for each signature.
+	for _, id := range ids {
+		k, err := getKey(id.key)
+		if err != nil {
+			return err
+		}
+		if err := a.openIndex(ctx, k); err != nil {
+			return err
+		}
+		p, err := queryPass(tx, *k, id.name, sshKeyType)
+		if err != nil {
+			return err
+		}
+
+		pub, err := decodePubKey(k.pub)
+		if err != nil {
+			return err
+		}
+		priv, err := a.unlock(ctx, k)
+		if err != nil {
+			return err
+		}
+
+		dec, err := openPass(pub, priv, k.name, p.idxName, p.idxType, p.data)
+		if err != nil {
+			return err
+		}
+		var pass passSSHKey
+		if err := pass.UnmarshalText(dec); err != nil {
+			return err
+		}
+
+		ag.ids = append(ag.ids, sshIdentity{
+			key:  id.key,
+			name: id.name,
+			pub:  pass.signer.PublicKey(),
+		})
+	}
+
+	lockKeys()
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	path := *sock
+	if path == "" {
+		dir, err := ioutil.TempDir(os.Getenv("XDG_RUNTIME_DIR"), "npass-agent-")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(dir)
+		path = filepath.Join(dir, "agent.sock")
+	}
+
+	l, err := listenPrivate(path)
+	if err != nil {
+		return err
+	}
+	defer l.Close()
+
+	fmt.Fprintf(a.w, "SSH_AUTH_SOCK=%s; export SSH_AUTH_SOCK;\n", path)
+
+	go func() {
+		<-ctx.Done()
+		l.Close()
+	}()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer conn.Close()
+			_ = agent.ServeAgent(ag, conn)
+		}()
+	}
+}
+
+type sshIdentity struct {
+	key, name string
+	pub       ssh.PublicKey
+}
+
+// sshAgent serves ssh-key passes over the ssh-agent protocol. Keys are
+// decrypted for each signature, after confirmation through pinentry.
+type sshAgent struct {
+	a *app
+	// agent.Agent methods take no context, so the serving context is kept
+	ctx context.Context
+
+	mu  sync.Mutex
+	ids []sshIdentity
+}
+
+var _ agent.ExtendedAgent = (*sshAgent)(nil) // Static interface check
+
+func (ag *sshAgent) List() ([]*agent.Key, error) {
+	keys := make([]*agent.Key, 0, len(ag.ids))
+	for _, id := range ag.ids {
+		keys = append(keys, &agent.Key{
+			Format:  id.pub.Type(),
+			Blob:    id.pub.Marshal(),
+			Comment: fmt.Sprintf("%s:%s:%s", id.key, id.name, sshKeyType),
+		})
+	}
+	return keys, nil
+}
+
+func (ag *sshAgent) Sign(key ssh.PublicKey, data []byte) (*ssh.Signature, error) {
+	return ag.SignWithFlags(key, data, 0)
+}
+
+func (ag *sshAgent) SignWithFlags(key ssh.PublicKey, data []byte, flags agent.SignatureFlags) (*ssh.Signature, error) {
+	// Only one pinentry dialog at a time
+	ag.mu.Lock()
+	defer ag.mu.Unlock()
+
+	var id *sshIdentity
+	for i := range ag.ids {
+		if bytes.Equal(ag.ids[i].pub.Marshal(), key.Marshal()) {
+			id = &ag.ids[i]
+			break
+		}
+	}
+	if id == nil {
+		return nil, fmt.Errorf("unknown key")
+	}
+
+	fullName := fmt.Sprintf("%s:%s:%s", id.key, id.name, sshKeyType)
+	if err := ag.a.confirm(ag.ctx, fmt.Sprintf("Allow use of ssh key %q?", fullName)); err != nil {
+		return nil, err
+	}
+
+	tx, err := ag.a.st.BeginTx(ag.ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	k, err := queryKey(tx, id.key)
+	if err != nil {
+		return nil, err
+	}
+	if err := ag.a.openIndex(ag.ctx, &k); err != nil {
+		return nil, err
+	}
+	p, err := queryPass(tx, k, id.name, sshKeyType)
+	if err != nil {
+		return nil, err
+	}
+
+	pass, err := ag.a.unlockPass(ag.ctx, tx, k, p)
+	if err != nil {
+		return nil, err
+	}
+	signer := pass.(*passSSHKey).signer
+
+	if !bytes.Equal(signer.PublicKey().Marshal(), key.Marshal()) {
+		return nil, fmt.Errorf("ssh key %q has changed", fullName)
+	}
+
+	if err := accessPass(tx, k, p); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	if flags == 0 {
+		return signer.Sign(rand.Reader, data)
+	}
+
+	as, ok := signer.(ssh.AlgorithmSigner)
+	if !ok {
+		return nil, fmt.Errorf("unsupported signature flags: %d", flags)
+	}
+	switch flags {
+	case agent.SignatureFlagRsaSha256:
+		return as.SignWithAlgorithm(rand.Reader, data, ssh.SigAlgoRSASHA2256)
+	case agent.SignatureFlagRsaSha512:
+		return as.SignWithAlgorithm(rand.Reader, data, ssh.SigAlgoRSASHA2512)
+	}
+	return nil, fmt.Errorf("unsupported signature flags: %d", flags)
+}
+
+func (ag *sshAgent) Add(agent.AddedKey) error       { return errAgentUnsupported }
+func (ag *sshAgent) Remove(ssh.PublicKey) error     { return errAgentUnsupported }
+func (ag *sshAgent) RemoveAll() error               { return errAgentUnsupported }
+func (ag *sshAgent) Lock([]byte) error              { return errAgentUnsupported }
+func (ag *sshAgent) Unlock([]byte) error            { return errAgentUnsupported }
+func (ag *sshAgent) Signers() ([]ssh.Signer, error) { return nil, errAgentUnsupported }
+
+func (ag *sshAgent) Extension(string, []byte) ([]byte, error) {
+	return nil, agent.ErrExtensionUnsupported
+}
@@ -0,0 +1,15 @@
+//go:build !(aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris)
+// +build !aix,!darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris
+
+package main
+
+import (
+	"errors"
+	"net"
+)
+
+// listenPrivate is only supported where the socket can be created with a
+// private mode.
+func listenPrivate(path string) (net.Listener, error) {
+	return nil, errors.New("ssh-agent is not supported on this platform")
+}
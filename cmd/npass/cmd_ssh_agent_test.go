@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh/agent"
+)
+
+func testSSHAgent(t *testing.T, pin *testPinentry) agent.ExtendedAgent {
+	ctx, cancel := context.WithCancel(context.Background())
+	app, _ := testNewApp(t, pin)
+
+	app.r = bytes.NewReader(testSSHKey(t, "ssh_ed25519"))
+	if err := app.run(ctx, []string{"new", "test-1:deploy:ssh-key"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	dir, err := ioutil.TempDir("", "npass-test-")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	sock := filepath.Join(dir, "agent.sock")
+
+	r, w := io.Pipe()
+	app.w = w
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		err := app.run(ctx, []string{"ssh-agent", "-a", sock})
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		w.Close()
+	}()
+
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "SSH_AUTH_SOCK=" + sock + "; export SSH_AUTH_SOCK;\n"; line != want {
+		t.Fatalf("ssh-agent out = %q; want %q", line, want)
+	}
+	if fi, err := os.Stat(sock); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	} else if fi.Mode().Perm() != 0600 {
+		t.Errorf("socket mode = %v; want %v", fi.Mode().Perm(), os.FileMode(0600))
+	}
+
+	conn, err := net.Dial("unix", sock)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	t.Cleanup(func() {
+		conn.Close()
+		cancel()
+		<-done
+		os.RemoveAll(dir)
+	})
+
+	return agent.NewClient(conn)
+}
+
+func TestCmdSSHAgent(t *testing.T) {
+	ag := testSSHAgent(t, &testPinentry{pass: "pass-1", confirm: true})
+
+	keys, err := ag.List()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(keys) != 1 || keys[0].Comment != "test-1:deploy:ssh-key" {
+		t.Fatalf("List() = %v; want single key", keys)
+	}
+
+	data := []byte("testing")
+	sig, err := ag.Sign(keys[0], data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := keys[0].Verify(data, sig); err != nil {
+		t.Errorf("signature verification failed: %v", err)
+	}
+
+	if err := ag.RemoveAll(); err == nil {
+		t.Errorf("RemoveAll() did not error; want error")
+	}
+}
+
+func TestCmdSSHAgentConfirmFail(t *testing.T) {
+	ag := testSSHAgent(t, &testPinentry{pass: "pass-1", confirm: false})
+
+	keys, err := ag.List()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	_, err = ag.Sign(keys[0], []byte("testing"))
+	if err == nil {
+		t.Errorf("Sign() did not error; want error")
+	}
+}
+
+func TestCmdSSHAgentFail(t *testing.T) {
+	ctx := context.Background()
+	app, _ := testNewApp(t, &testPinentry{pass: "pass-1"})
+
+	err := app.run(ctx, []string{"ssh-agent", "test-1:test-1:pass"})
+	if !errors.Is(err, errUsage) {
+		t.Errorf("ssh-agent err = %v; want %v", err, errUsage)
+	}
+
+	err = app.run(ctx, []string{"ssh-agent"})
+	if err == nil || !strings.Contains(err.Error(), "no ssh keys") {
+		t.Errorf("ssh-agent err = %v; want no ssh keys", err)
+	}
+}
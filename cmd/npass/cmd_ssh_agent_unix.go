@@ -0,0 +1,18 @@
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build aix darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package main
+
+import (
+	"net"
+	"syscall"
+)
+
+// listenPrivate listens on a unix socket at path, accessible only to the user
+// from its creation, as changing its mode afterwards would leave a window in
+// which others could connect.
+func listenPrivate(path string) (net.Listener, error) {
+	old := syscall.Umask(0177)
+	defer syscall.Umask(old)
+	return net.Listen("unix", path)
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var errTag = errors.New("invalid tag")
+
+func (a *app) cmdTag(ctx context.Context, args []string) error {
+	fs := newFlagSet("tag")
+	detach := fs.Bool("d", false, "detach the tags instead of attaching them")
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	args = fs.Args()
+
+	if len(args) < 1 || (*detach && len(args) < 2) {
+		return errUsage
+	}
+
+	key, name, typ, err := parseIdentifier(args[0])
+	if err != nil {
+		return err
+	}
+	if key == "" || name == "" || typ == "" {
+		return errUsage
+	}
+	fullName := fmt.Sprintf("%s:%s:%s", key, name, typ)
+
+	tags := args[1:]
+	for _, tag := range tags {
+		if tag == "" || !containsOnly(tag, charsetTag) {
+			return fmt.Errorf("%w: %q", errTag, tag)
+		}
+	}
+
+	tx, err := a.st.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	k, err := queryKey(tx, key)
+	if err != nil {
+		return err
+	}
+	// Tags are stored in the clear, and would leak what the index hides
+	if k.privateIndex {
+		return fmt.Errorf("key %q has a private index, which does not support tags", key)
+	}
+	p, err := queryPass(tx, k, name, typ)
+	if err != nil {
+		return err
+	}
+
+	for _, tag := range tags {
+		if !*detach {
+			queryInsert := `INSERT OR IGNORE INTO tags (pass_id, tag) VALUES(?, ?)`
+			if _, err := tx.Exec(queryInsert, p.id, tag); err != nil {
+				return err
+			}
+			continue
+		}
+
+		queryDelete := `DELETE FROM tags WHERE pass_id = ? AND tag = ?`
+		res, err := tx.Exec(queryDelete, p.id, tag)
+		if err != nil {
+			return err
+		}
+		if n, err := res.RowsAffected(); err != nil {
+			return err
+		} else if n == 0 {
+			return fmt.Errorf("non-existent tag %q on pass %q", tag, fullName)
+		}
+	}
+
+	all, err := queryTags(tx, p.id)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(a.w, strings.Join(append([]string{fullName + ":"}, all...), " "))
+	return nil
+}
+
+// queryTags returns the tags of the pass with the given id, in order.
+func queryTags(tx *sql.Tx, passID int64) ([]string, error) {
+	query := `SELECT tag FROM tags WHERE pass_id = ? ORDER BY tag`
+	rows, err := tx.Query(query, passID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tags []string
+	for rows.Next() {
+		var tag string
+		if err := rows.Scan(&tag); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
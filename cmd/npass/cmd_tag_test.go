@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestCmdTag(t *testing.T) {
+	ctx := context.Background()
+	app, out := testNewApp(t, &testPinentry{})
+
+	tests := []struct {
+		args []string
+		out  string
+	}{
+		{[]string{"tag", "test-1:test-1:pass"}, "test-1:test-1:pass:\n"},
+		{[]string{"tag", "test-1:test-1:pass", "work", "ci"}, "test-1:test-1:pass: ci work\n"},
+		{[]string{"tag", "test-1:test-1:pass", "work"}, "test-1:test-1:pass: ci work\n"},
+		{[]string{"tag", "-d", "test-1:test-1:pass", "ci"}, "test-1:test-1:pass: work\n"},
+	}
+
+	for _, tt := range tests {
+		out.Reset()
+		err := app.run(ctx, tt.args)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if out.String() != tt.out {
+			t.Errorf("%v out = %q; want %q", tt.args, out.String(), tt.out)
+		}
+	}
+}
+
+func TestCmdTagRm(t *testing.T) {
+	ctx := context.Background()
+	app, _ := testNewApp(t, &testPinentry{confirm: true})
+
+	err := app.run(ctx, []string{"tag", "test-1:test-1:pass", "work"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Tags are removed along with their pass
+	for _, args := range [][]string{
+		{"rm", "test-1:test-1:pass"},
+		{"empty-trash"},
+	} {
+		if err := app.run(ctx, args); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	var count int
+	err = app.st.QueryRow(`SELECT COUNT(*) FROM tags`).Scan(&count)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if count != 0 {
+		t.Errorf("tags left after rm = %d; want 0", count)
+	}
+}
+
+func TestCmdTagFail(t *testing.T) {
+	ctx := context.Background()
+	app, _ := testNewApp(t, &testPinentry{})
+
+	tests := []struct {
+		args []string
+		err  error
+	}{
+		{[]string{"tag"}, errUsage},
+		{[]string{"tag", "-d", "test-1:test-1:pass"}, errUsage},
+		{[]string{"tag", "test-1:test-1"}, errUsage},
+		{[]string{"tag", "test-1:test-1:pass", "Invalid"}, fmt.Errorf("%w: %q", errTag, "Invalid")},
+		{[]string{"tag", "test-1:none:pass", "work"}, fmt.Errorf("non-existent pass %q", "test-1:none:pass")},
+		{[]string{"tag", "-d", "test-1:test-1:pass", "work"},
+			fmt.Errorf("non-existent tag %q on pass %q", "work", "test-1:test-1:pass")},
+	}
+
+	for _, tt := range tests {
+		err := app.run(ctx, tt.args)
+		if !errors.Is(err, tt.err) && !reflect.DeepEqual(err, tt.err) {
+			t.Errorf("%v err = %v; want %v", tt.args, err, tt.err)
+		}
+	}
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+func (a *app) cmdTrash(ctx context.Context, args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+
+	tx, err := a.st.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	queryKeys := `SELECT name, trashed FROM keys WHERE trashed IS NOT NULL ORDER BY name`
+	rows, err := tx.Query(queryKeys)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			key     string
+			trashed int64
+		)
+		if err := rows.Scan(&key, &trashed); err != nil {
+			return err
+		}
+		fmt.Fprintf(a.w, "%s (trashed %s)\n", key, formatTrashed(trashed))
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	queryPass := `SELECT keys.id, keys.name, keys.public, keys.private, keys.private_index,
+	pass.name, pass.type, pass.sealed_name, pass.trashed
+	FROM pass JOIN keys ON pass.key_id = keys.id WHERE pass.trashed IS NOT NULL`
+	rows, err = tx.Query(queryPass)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	type trashedPass struct {
+		k       *dbKey
+		p       dbPass
+		trashed int64
+	}
+
+	keys := make(map[int64]*dbKey)
+	var pass []trashedPass
+	for rows.Next() {
+		var (
+			k  dbKey
+			tp trashedPass
+		)
+		err := rows.Scan(&k.id, &k.name, &k.pub, &k.priv, &k.privateIndex,
+			&tp.p.idxName, &tp.p.idxType, &tp.p.sealedName, &tp.trashed)
+		if err != nil {
+			return err
+		}
+		if _, ok := keys[k.id]; !ok {
+			keys[k.id] = &k
+		}
+		tp.k = keys[k.id]
+		pass = append(pass, tp)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	// Names under a private index are decrypted, unlocking their keys once
+	for i, tp := range pass {
+		if err := a.openIndex(ctx, tp.k); err != nil {
+			return err
+		}
+		pass[i].p.name, pass[i].p.typ, err = tp.k.passName(tp.p.idxName, tp.p.idxType, tp.p.sealedName)
+		if err != nil {
+			return err
+		}
+	}
+	sort.Slice(pass, func(i, j int) bool {
+		p, q := pass[i], pass[j]
+		if p.k.name != q.k.name {
+			return p.k.name < q.k.name
+		}
+		if p.p.name != q.p.name {
+			return p.p.name < q.p.name
+		}
+		return p.p.typ < q.p.typ
+	})
+
+	for _, tp := range pass {
+		fmt.Fprintf(a.w, "%s:%s:%s (trashed %s)\n", tp.k.name, tp.p.name, tp.p.typ, formatTrashed(tp.trashed))
+	}
+
+	return tx.Commit()
+}
+
+func formatTrashed(t int64) string {
+	return time.Unix(t, 0).Format("2006-01-02 15:04")
+}
+
+func (a *app) cmdRestore(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+
+	key, name, typ, err := parseIdentifier(args[0])
+	if err != nil {
+		return err
+	}
+	if key == "" {
+		return errUsage
+	}
+
+	tx, err := a.st.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var (
+		k       = dbKey{name: key}
+		trashed sql.NullInt64
+	)
+	queryKey := `SELECT id, public, private, private_index, trashed FROM keys WHERE name = ? LIMIT 1`
+	err = tx.QueryRow(queryKey, key).Scan(&k.id, &k.pub, &k.priv, &k.privateIndex, &trashed)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("non-existent key %q", key)
+	}
+	if err != nil {
+		return err
+	}
+
+	if name == "" {
+		if !trashed.Valid {
+			return fmt.Errorf("key %q is not in the trash", key)
+		}
+
+		// Passes trashed along with the key are restored with it
+		queryRestorePass := `UPDATE pass SET trashed = NULL WHERE key_id = ? AND trashed = ?`
+		if _, err := tx.Exec(queryRestorePass, k.id, trashed.Int64); err != nil {
+			return err
+		}
+		queryRestoreKey := `UPDATE keys SET trashed = NULL WHERE id = ?`
+		if _, err := tx.Exec(queryRestoreKey, k.id); err != nil {
+			return err
+		}
+
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		fmt.Fprintf(a.w, "restored key %q\n", key)
+		return nil
+	}
+
+	if trashed.Valid {
+		return fmt.Errorf("key %q is in the trash", key)
+	}
+
+	if err := a.openIndex(ctx, &k); err != nil {
+		return err
+	}
+	idxName, idxType, err := k.passIndex(name, typ)
+	if err != nil {
+		return err
+	}
+
+	fullName := fmt.Sprintf("%s:%s", key, name)
+	query := `UPDATE pass SET trashed = NULL WHERE key_id = ? AND name = ? AND trashed IS NOT NULL`
+	queryArgs := []interface{}{k.id, idxName}
+	if typ != "" {
+		fullName = fmt.Sprintf("%s:%s:%s", key, name, typ)
+		query += ` AND type = ?`
+		queryArgs = append(queryArgs, idxType)
+	}
+
+	res, err := tx.Exec(query, queryArgs...)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return fmt.Errorf("pass %q is not in the trash", fullName)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	fmt.Fprintf(a.w, "restored pass %q\n", fullName)
+	return nil
+}
+
+// cmdEmptyTrash permanently deletes trashed keys and passes. The store is
+// opened with _secure_delete, so their contents are overwritten on disk.
+func (a *app) cmdEmptyTrash(ctx context.Context, args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+
+	tx, err := a.st.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var keys, pass int
+	queryCountKeys := `SELECT COUNT(*) FROM keys WHERE trashed IS NOT NULL`
+	if err := tx.QueryRow(queryCountKeys).Scan(&keys); err != nil {
+		return err
+	}
+	queryCountPass := `SELECT COUNT(*) FROM pass WHERE trashed IS NOT NULL`
+	if err := tx.QueryRow(queryCountPass).Scan(&pass); err != nil {
+		return err
+	}
+	if keys == 0 && pass == 0 {
+		fmt.Fprintln(a.w, "trash is empty")
+		return nil
+	}
+
+	prompt := fmt.Sprintf("Permanently delete %d keys and %d passes?", keys, pass)
+	if err := a.confirm(ctx, prompt); err != nil {
+		return err
+	}
+
+	// Tags and history are deleted along with their passes
+	queryDeletePass := `DELETE FROM pass WHERE trashed IS NOT NULL`
+	if _, err := tx.Exec(queryDeletePass); err != nil {
+		return err
+	}
+	queryDeleteKeys := `DELETE FROM keys WHERE trashed IS NOT NULL`
+	if _, err := tx.Exec(queryDeleteKeys); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	fmt.Fprintf(a.w, "deleted %d keys and %d passes\n", keys, pass)
+	return nil
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCmdTrash(t *testing.T) {
+	ctx := context.Background()
+	pin := &testPinentry{pass: "pass-1", confirm: true}
+	app, out := testNewApp(t, pin)
+
+	now := time.Date(2020, 1, 1, 12, 0, 0, 0, time.Local)
+	testTimeNow(t, now)
+
+	tests := []struct {
+		args []string
+		out  string
+	}{
+		{[]string{"rm", "test-1:test-1:pass"}, "moved pass \"test-1:test-1:pass\" to trash\n"},
+		{[]string{"rm", "-r", "test-2"}, "moved key \"test-2\" to trash\n"},
+		{[]string{"trash"}, "test-2 (trashed 2020-01-01 12:00)\ntest-1:test-1:pass (trashed 2020-01-01 12:00)\ntest-2:test-2:pass (trashed 2020-01-01 12:00)\n"},
+		{[]string{"show"}, "test-1 5M60s3mDoFQJQOkZxyn0nOo2VuKzdUJZU60j3Ymkny4:\n"},
+		{[]string{"show", "test-1"}, "test-1 5M60s3mDoFQJQOkZxyn0nOo2VuKzdUJZU60j3Ymkny4:\n"},
+		{[]string{"restore", "test-1:test-1"}, "restored pass \"test-1:test-1\"\n"},
+		{[]string{"show", "test-1:test-1:pass"}, "pass-1\n"},
+		{[]string{"restore", "test-2"}, "restored key \"test-2\"\n"},
+		{[]string{"show"}, "test-1 5M60s3mDoFQJQOkZxyn0nOo2VuKzdUJZU60j3Ymkny4:\n  test-1: [pass]\ntest-2 VIFZnL4uDjJIwU61yIN0NV5ORYdehWU2PYeTwMwDvwc:\n  test-2: [pass]\n"},
+		{[]string{"trash"}, ""},
+	}
+
+	for _, tt := range tests {
+		out.Reset()
+		err := app.run(ctx, tt.args)
+		if err != nil {
+			t.Fatalf("%v: unexpected error: %v", tt.args, err)
+		}
+		if out.String() != tt.out {
+			t.Errorf("%v out = %q; want %q", tt.args, out.String(), tt.out)
+		}
+	}
+}
+
+func TestCmdTrashFail(t *testing.T) {
+	ctx := context.Background()
+	app, _ := testNewApp(t, &testPinentry{pass: "pass-1", confirm: true})
+
+	for _, args := range [][]string{
+		{"rm", "test-1:test-1:pass"},
+		{"rm", "-r", "test-2"},
+	} {
+		if err := app.run(ctx, args); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	tests := []struct {
+		args []string
+		err  error
+	}{
+		{[]string{"trash", "test-1"}, errUsage},
+		{[]string{"restore"}, errUsage},
+		{[]string{"empty-trash", "test-1"}, errUsage},
+		{[]string{"new", "test-1:test-1:pass"}, fmt.Errorf("pass %q is in the trash", "test-1:test-1:pass")},
+		{[]string{"new", "test-2"}, fmt.Errorf("key %q is in the trash", "test-2")},
+		{[]string{"show", "test-1:test-1:pass"}, fmt.Errorf("non-existent pass %q", "test-1:test-1")},
+		{[]string{"restore", "test-2:test-2:pass"}, fmt.Errorf("key %q is in the trash", "test-2")},
+		{[]string{"restore", "test-1"}, fmt.Errorf("key %q is not in the trash", "test-1")},
+		{[]string{"restore", "test-1:none"}, fmt.Errorf("pass %q is not in the trash", "test-1:none")},
+		{[]string{"restore", "test-none"}, fmt.Errorf("non-existent key %q", "test-none")},
+	}
+
+	for _, tt := range tests {
+		err := app.run(ctx, tt.args)
+		if !errors.Is(err, tt.err) && !reflect.DeepEqual(err, tt.err) {
+			t.Errorf("%v err = %v; want %v", tt.args, err, tt.err)
+		}
+	}
+}
+
+func TestCmdEmptyTrash(t *testing.T) {
+	ctx := context.Background()
+	pin := &testPinentry{pass: "pass-1", confirm: true}
+	app, out := testNewApp(t, pin)
+
+	for _, args := range [][]string{
+		{"rm", "test-1:test-1:pass"},
+		{"rm", "-r", "test-2"},
+	} {
+		if err := app.run(ctx, args); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	pin.confirm = false
+	err := app.run(ctx, []string{"empty-trash"})
+	if !errors.Is(err, errCancelled) {
+		t.Errorf("empty-trash err = %v; want %v", err, errCancelled)
+	}
+
+	pin.confirm = true
+	out.Reset()
+	if err := app.run(ctx, []string{"empty-trash"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "deleted 1 keys and 2 passes\n"; out.String() != want {
+		t.Errorf("empty-trash out = %q; want %q", out.String(), want)
+	}
+
+	var count int
+	err = app.st.QueryRow(`SELECT (SELECT COUNT(*) FROM keys) + (SELECT COUNT(*) FROM pass)`).Scan(&count)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("rows left = %d; want %d", count, 1)
+	}
+
+	// Identifiers can be reused once the trash is emptied
+	if err := app.run(ctx, []string{"new", "test-1:test-1:pass"}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	out.Reset()
+	if err := app.run(ctx, []string{"empty-trash"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "trash is empty\n"; out.String() != want {
+		t.Errorf("empty-trash out = %q; want %q", out.String(), want)
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+)
+
+// cmdUpgrade reseals keys and passes still in a legacy layout, or not padded
+// as their key requires. Rows are also upgraded lazily as they are unlocked,
+// so this is only needed to upgrade everything at once, or to change the
+// padding of a key.
+func (a *app) cmdUpgrade(ctx context.Context, args []string) error {
+	fs := newFlagSet("upgrade")
+	dryRun := fs.Bool("n", false, "only list the keys and passes to upgrade")
+	padding := fs.Int("padding", 0, "change the padding of the key to a power of two of at least this many bytes, or 0 to disable")
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	args = fs.Args()
+
+	var paddingSet bool
+	fs.Visit(func(f *flag.Flag) { paddingSet = paddingSet || f.Name == "padding" })
+
+	if len(args) > 1 {
+		return errUsage
+	}
+	var key string
+	if len(args) == 1 {
+		var name, typ string
+		var err error
+		key, name, typ, err = parseIdentifier(args[0])
+		if err != nil {
+			return err
+		}
+		if name != "" || typ != "" {
+			return errUsage
+		}
+	}
+	if paddingSet {
+		if key == "" {
+			return errUsage
+		}
+		if err := checkPadding(*padding); err != nil {
+			return err
+		}
+	}
+
+	tx, err := a.st.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var (
+		keys     []dbKey
+		upgraded int
+		// Whether the padding changed, so that every pass is resealed, as a
+		// larger bucket than needed still looks current.
+		repad bool
+	)
+	if key != "" {
+		k, err := queryKey(tx, key)
+		if err != nil {
+			return err
+		}
+
+		// Passes are resealed below to match the new padding
+		if paddingSet && k.padding != *padding {
+			k.padding = *padding
+			repad = true
+			if *dryRun {
+				fmt.Fprintf(a.w, "padding of key %q: %d\n", k.name, k.padding)
+			} else {
+				queryPadding := `UPDATE keys SET padding = ? WHERE id = ?`
+				if _, err := tx.Exec(queryPadding, k.padding, k.id); err != nil {
+					return err
+				}
+				fmt.Fprintf(a.w, "set padding of key %q to %d\n", k.name, k.padding)
+			}
+			upgraded++
+		}
+		keys = append(keys, k)
+	} else {
+		queryKeys := `SELECT id, name, public, private, private_index, padding FROM keys WHERE trashed IS NULL ORDER BY name`
+		rows, err := tx.Query(queryKeys)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var k dbKey
+			if err := rows.Scan(&k.id, &k.name, &k.pub, &k.priv, &k.privateIndex, &k.padding); err != nil {
+				return err
+			}
+			keys = append(keys, k)
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		rows.Close()
+	}
+
+	for _, k := range keys {
+		// Trashed passes are upgraded too, so that they can still be restored
+		pass, err := legacyPasses(tx, k)
+		if repad {
+			pass, err = queryPasses(tx, k, "")
+		}
+		if err != nil {
+			return err
+		}
+		if isCurrent(k.priv, algKey) && len(pass) == 0 {
+			continue
+		}
+
+		if *dryRun {
+			if !isCurrent(k.priv, algKey) {
+				fmt.Fprintf(a.w, "key %q\n", k.name)
+				upgraded++
+			}
+			upgraded += len(pass)
+			// Names under a private index are unknown until unlocking
+			if k.privateIndex && len(pass) > 0 {
+				fmt.Fprintf(a.w, "%d passes of key %q\n", len(pass), k.name)
+				pass = nil
+			}
+			for _, p := range pass {
+				fmt.Fprintf(a.w, "pass %q\n", fmt.Sprintf("%s:%s:%s", k.name, p.name, p.typ))
+			}
+			continue
+		}
+
+		pub, err := decodePubKey(k.pub)
+		if err != nil {
+			return err
+		}
+		priv, err := a.unlock(ctx, &k)
+		if err != nil {
+			return err
+		}
+		if err := namePasses(k, pass); err != nil {
+			return err
+		}
+
+		if ok, err := upgradeKey(tx, k, priv, k.password); err != nil {
+			return err
+		} else if ok {
+			fmt.Fprintf(a.w, "upgraded key %q\n", k.name)
+			upgraded++
+		}
+
+		for _, p := range pass {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			fullName := fmt.Sprintf("%s:%s:%s", k.name, p.name, p.typ)
+
+			dec, err := openPass(pub, priv, k.name, p.idxName, p.idxType, p.data)
+			if err != nil {
+				return fmt.Errorf("could not decrypt pass %q: %w", fullName, err)
+			}
+			if err := resealPassData(tx, k, p, dec); err != nil {
+				return err
+			}
+
+			fmt.Fprintf(a.w, "upgraded pass %q\n", fullName)
+			upgraded++
+		}
+	}
+
+	if upgraded == 0 {
+		fmt.Fprintln(a.w, "nothing to upgrade")
+	}
+	if *dryRun {
+		if upgraded != 0 {
+			fmt.Fprintf(a.w, "%d to upgrade\n", upgraded)
+		}
+		return nil
+	}
+	return tx.Commit()
+}
+
+// legacyPasses returns the passes under k, trashed or not, still in a legacy
+// layout.
+func legacyPasses(tx *sql.Tx, k dbKey) ([]dbPass, error) {
+	all, err := queryPasses(tx, k, "")
+	if err != nil {
+		return nil, err
+	}
+
+	var pass []dbPass
+	for _, p := range all {
+		if !k.isCurrentPass(p.data) {
+			pass = append(pass, p)
+		}
+	}
+	return pass, nil
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"golang.org/x/crypto/nacl/box"
+	"golang.org/x/crypto/poly1305"
+)
+
+// testLegacyRows returns the number of keys and passes in a legacy layout.
+func testLegacyRows(t *testing.T, a *app) (keys, pass int) {
+	t.Helper()
+
+	for _, tc := range []struct {
+		query   string
+		current func(k dbKey, enc string) bool
+		n       *int
+	}{
+		{`SELECT padding, private FROM keys`, func(_ dbKey, enc string) bool { return isCurrent(enc, algKey) }, &keys},
+		{`SELECT keys.padding, pass.data FROM pass JOIN keys ON pass.key_id = keys.id`, dbKey.isCurrentPass, &pass},
+	} {
+		rows, err := a.st.Query(tc.query)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		for rows.Next() {
+			var (
+				k   dbKey
+				enc string
+			)
+			if err := rows.Scan(&k.padding, &enc); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !tc.current(k, enc) {
+				*tc.n++
+			}
+		}
+		if err := rows.Close(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	return keys, pass
+}
+
+func TestCmdUpgrade(t *testing.T) {
+	ctx := context.Background()
+	pin := &testPinentry{pass: "pass-1"}
+	app, out := testNewApp(t, pin)
+
+	err := app.run(ctx, []string{"upgrade", "-n"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "key \"test-1\"\npass \"test-1:test-1:pass\"\nkey \"test-2\"\npass \"test-2:test-2:pass\"\n4 to upgrade\n"
+	if out.String() != want {
+		t.Errorf("upgrade -n out = %q; want %q", out.String(), want)
+	}
+	if keys, pass := testLegacyRows(t, app); keys != 2 || pass != 2 {
+		t.Errorf("legacy rows = %d keys and %d passes; want 2 and 2", keys, pass)
+	}
+
+	out.Reset()
+	err = app.run(ctx, []string{"upgrade", "test-1"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want = "upgraded key \"test-1\"\nupgraded pass \"test-1:test-1:pass\"\n"
+	if out.String() != want {
+		t.Errorf("upgrade out = %q; want %q", out.String(), want)
+	}
+	if keys, pass := testLegacyRows(t, app); keys != 1 || pass != 1 {
+		t.Errorf("legacy rows = %d keys and %d passes; want 1 and 1", keys, pass)
+	}
+
+	out.Reset()
+	pin.pass = "pass-2"
+	err = app.run(ctx, []string{"upgrade"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want = "upgraded key \"test-2\"\nupgraded pass \"test-2:test-2:pass\"\n"
+	if out.String() != want {
+		t.Errorf("upgrade out = %q; want %q", out.String(), want)
+	}
+
+	out.Reset()
+	err = app.run(ctx, []string{"upgrade"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "nothing to upgrade\n"; out.String() != want {
+		t.Errorf("upgrade out = %q; want %q", out.String(), want)
+	}
+
+	out.Reset()
+	err = app.run(ctx, []string{"show", "test-2:test-2:pass"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "pass-2\n"; out.String() != want {
+		t.Errorf("show out = %q; want %q", out.String(), want)
+	}
+}
+
+func TestCmdUpgradeLazy(t *testing.T) {
+	ctx := context.Background()
+	pin := &testPinentry{pass: "pass-1"}
+	app, out := testNewApp(t, pin)
+
+	err := app.run(ctx, []string{"show", "test-1:test-1:pass"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if keys, pass := testLegacyRows(t, app); keys != 1 || pass != 1 {
+		t.Errorf("legacy rows = %d keys and %d passes; want 1 and 1", keys, pass)
+	}
+
+	out.Reset()
+	err = app.run(ctx, []string{"show", "test-1:test-1:pass"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "pass-1\n"; out.String() != want {
+		t.Errorf("show out = %q; want %q", out.String(), want)
+	}
+}
+
+func TestCmdUpgradeSealedBox(t *testing.T) {
+	ctx := context.Background()
+	pin := &testPinentry{pass: "pass-1"}
+	app, out := testNewApp(t, pin)
+
+	if err := app.run(ctx, []string{"upgrade", "test-1"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Envelopes with an outdated algorithm are upgraded too
+	pub, err := decodePubKey("5M60s3mDoFQJQOkZxyn0nOo2VuKzdUJZU60j3Ymkny4")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	raw, err := box.SealAnonymous(nil, []byte("test-1:test-1:pass:pass-1"), pub, rand.Reader)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	enc := envelope{alg: algX25519SealedBox, data: raw}.encode()
+	if _, err := app.st.Exec(`UPDATE pass SET data = ? WHERE id = 1`, enc); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	out.Reset()
+	if err := app.run(ctx, []string{"upgrade", "test-1"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "upgraded pass \"test-1:test-1:pass\"\n"; out.String() != want {
+		t.Errorf("upgrade out = %q; want %q", out.String(), want)
+	}
+	if keys, pass := testLegacyRows(t, app); keys != 1 || pass != 1 {
+		t.Errorf("legacy rows = %d keys and %d passes; want 1 and 1", keys, pass)
+	}
+
+	out.Reset()
+	if err := app.run(ctx, []string{"show", "test-1:test-1:pass"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "pass-1\n"; out.String() != want {
+		t.Errorf("show out = %q; want %q", out.String(), want)
+	}
+}
+
+func TestCmdUpgradeFail(t *testing.T) {
+	ctx := context.Background()
+	app, _ := testNewApp(t, &testPinentry{pass: "incorrect"})
+
+	for _, args := range [][]string{
+		{"upgrade", "test-1", "test-2"},
+		{"upgrade", "test-1:test-1"},
+		{"upgrade", "-x"},
+		{"upgrade", "-padding", "64"},
+	} {
+		if err := app.run(ctx, args); !errors.Is(err, errUsage) {
+			t.Errorf("%q err = %v; want %v", args, err, errUsage)
+		}
+	}
+
+	err := app.run(ctx, []string{"upgrade", "test-none"})
+	if want := fmt.Errorf("non-existent key %q", "test-none"); !reflect.DeepEqual(err, want) {
+		t.Errorf("upgrade err = %v; want %v", err, want)
+	}
+
+	err = app.run(ctx, []string{"upgrade", "-padding", "100", "test-1"})
+	if !errors.Is(err, errPadding) {
+		t.Errorf("upgrade err = %v; want %v", err, errPadding)
+	}
+
+	err = app.run(ctx, []string{"upgrade"})
+	if !errors.Is(err, errTestPinentryVerify) {
+		t.Errorf("upgrade err = %v; want %v", err, errTestPinentryVerify)
+	}
+	if keys, pass := testLegacyRows(t, app); keys != 2 || pass != 2 {
+		t.Errorf("legacy rows = %d keys and %d passes; want 2 and 2", keys, pass)
+	}
+}
+
+func TestCmdUpgradePadding(t *testing.T) {
+	ctx := context.Background()
+	pin := &testPinentry{pass: "pass-1"}
+	app, out := testNewApp(t, pin)
+
+	// Size of the sealed plaintext of the pass, padding included
+	sealedSize := func() int {
+		var enc string
+		if err := app.st.QueryRow(`SELECT data FROM pass WHERE id = 1`).Scan(&enc); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		e, err := decodeEnvelope(enc)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return len(e.data) - poly1305.TagSize
+	}
+
+	tests := []struct {
+		args []string
+		out  string
+		size int
+	}{
+		{[]string{"upgrade", "test-1"}, "upgraded key \"test-1\"\nupgraded pass \"test-1:test-1:pass\"\n", 64},
+		{[]string{"upgrade", "-padding", "64", "test-1"}, "nothing to upgrade\n", 64},
+		{[]string{"upgrade", "-n", "-padding", "256", "test-1"}, "padding of key \"test-1\": 256\npass \"test-1:test-1:pass\"\n2 to upgrade\n", 64},
+		{[]string{"upgrade", "-padding", "256", "test-1"}, "set padding of key \"test-1\" to 256\nupgraded pass \"test-1:test-1:pass\"\n", 256},
+		{[]string{"upgrade", "test-1"}, "nothing to upgrade\n", 256},
+		// Shrinking buckets still look current, but are resealed
+		{[]string{"upgrade", "-padding", "64", "test-1"}, "set padding of key \"test-1\" to 64\nupgraded pass \"test-1:test-1:pass\"\n", 64},
+		{[]string{"upgrade", "-padding", "0", "test-1"}, "set padding of key \"test-1\" to 0\nupgraded pass \"test-1:test-1:pass\"\n", len("pass-1")},
+		{[]string{"show", "test-1:test-1:pass"}, "pass-1\n", len("pass-1")},
+	}
+
+	for _, tt := range tests {
+		out.Reset()
+		err := app.run(ctx, tt.args)
+		if err != nil {
+			t.Fatalf("%v: unexpected error: %v", tt.args, err)
+		}
+		if out.String() != tt.out {
+			t.Errorf("%v out = %q; want %q", tt.args, out.String(), tt.out)
+		}
+		if size := sealedSize(); size != tt.size {
+			t.Errorf("%v sealed size = %d; want %d", tt.args, size, tt.size)
+		}
+	}
+
+	var enc string
+	if err := app.st.QueryRow(`SELECT data FROM pass WHERE id = 1`).Scan(&enc); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !isCurrent(enc, algX25519XChaCha20Poly1305) {
+		t.Errorf("pass data = %q; want envelope with algorithm %d", enc, algX25519XChaCha20Poly1305)
+	}
+}
+
+func TestCmdUpgradeDryRunCount(t *testing.T) {
+	ctx := context.Background()
+	app, out := testNewApp(t, &testPinentry{pass: "pass-1"})
+
+	if err := app.run(ctx, []string{"upgrade", "test-1"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// The key is current, but its pass is not padded as required anymore
+	if _, err := app.st.Exec(`UPDATE keys SET padding = 256 WHERE name = 'test-1'`); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	out.Reset()
+	if err := app.run(ctx, []string{"upgrade", "-n", "test-1"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "pass \"test-1:test-1:pass\"\n1 to upgrade\n"; out.String() != want {
+		t.Errorf("upgrade -n out = %q; want %q", out.String(), want)
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Sealed values in keys.private and pass.data are stored as base64-encoded
+// envelopes:
+//
+//	magic      6 bytes, "npass\x00"
+//	version    1 byte, envelopeVersion
+//	algorithm  1 byte, one of the alg* constants
+//	params     1 byte length, followed by the algorithm parameters
+//	nonce      1 byte length, followed by the nonce
+//	ciphertext the rest
+//
+// Values written before envelopes were introduced have no magic, and are
+// opened with the legacy decoders. As the magic is a whole number of base64
+// groups, envelopes can be told apart by their encoded prefix.
+const (
+	envelopeMagic   = "npass\x00"
+	envelopeVersion = 1
+)
+
+// Envelope algorithms.
+const (
+	// keys.private: Argon2id and XSalsa20-Poly1305 (secretbox). The params
+	// are the kdfParams and the salt.
+	algArgon2idSecretbox = 1
+	// pass.data, legacy: X25519 and XSalsa20-Poly1305 sealed box
+	// (box.SealAnonymous), whose nonce is derived from the ephemeral key. No
+	// params or nonce, and the plaintext is prefixed by the identifier.
+	algX25519SealedBox = 2
+	// pass.data: ephemeral X25519, HKDF-SHA256 and XChaCha20-Poly1305. The
+	// params are the ephemeral public key, and the identifier is
+	// authenticated as associated data, see passAD.
+	algX25519XChaCha20Poly1305 = 3
+	// pass.data: as algX25519XChaCha20Poly1305, with the plaintext padded,
+	// see pad.
+	algX25519XChaCha20Poly1305Padded = 4
+)
+
+// Algorithm used to seal new keys. Passes are sealed with the algorithm
+// selected by the padding of their key, see (dbKey).passAlg.
+const algKey = algArgon2idSecretbox
+
+var (
+	errEnvelope        = errors.New("invalid envelope")
+	errEnvelopeVersion = errors.New("unsupported envelope version")
+)
+
+// envelopePrefix is the encoded prefix shared by all envelopes.
+var envelopePrefix = base64.RawStdEncoding.EncodeToString([]byte(envelopeMagic))
+
+type envelope struct {
+	alg    byte
+	params []byte
+	nonce  []byte
+	data   []byte
+}
+
+// isEnvelope reports whether enc is an envelope rather than a legacy value.
+func isEnvelope(enc string) bool {
+	return strings.HasPrefix(enc, envelopePrefix)
+}
+
+// isCurrent reports whether enc is an envelope sealed with alg, rather than a
+// legacy value or an envelope with an outdated algorithm.
+func isCurrent(enc string, alg byte) bool {
+	e, err := decodeEnvelope(enc)
+	return err == nil && e.alg == alg
+}
+
+// header returns everything before the ciphertext.
+func (e envelope) header() []byte {
+	var buf bytes.Buffer
+	buf.WriteString(envelopeMagic)
+	buf.WriteByte(envelopeVersion)
+	buf.WriteByte(e.alg)
+	buf.WriteByte(byte(len(e.params)))
+	buf.Write(e.params)
+	buf.WriteByte(byte(len(e.nonce)))
+	buf.Write(e.nonce)
+	return buf.Bytes()
+}
+
+func (e envelope) encode() string {
+	return base64.RawStdEncoding.EncodeToString(append(e.header(), e.data...))
+}
+
+// decodeEnvelope decodes an envelope. Callers check its algorithm.
+func decodeEnvelope(enc string) (envelope, error) {
+	raw, err := base64.RawStdEncoding.DecodeString(enc)
+	if err != nil || !bytes.HasPrefix(raw, []byte(envelopeMagic)) {
+		return envelope{}, errEnvelope
+	}
+	raw = raw[len(envelopeMagic):]
+
+	if len(raw) < 2 {
+		return envelope{}, errEnvelope
+	}
+	if raw[0] != envelopeVersion {
+		return envelope{}, fmt.Errorf("%w %d", errEnvelopeVersion, raw[0])
+	}
+	e := envelope{alg: raw[1]}
+	raw = raw[2:]
+
+	for _, field := range []*[]byte{&e.params, &e.nonce} {
+		if len(raw) < 1 || len(raw) < 1+int(raw[0]) {
+			return envelope{}, errEnvelope
+		}
+		*field = raw[1 : 1+int(raw[0])]
+		raw = raw[1+int(raw[0]):]
+	}
+	e.data = raw
+
+	return e, nil
+}
+
+// Size of the encoded kdfParams, before the salt.
+const kdfParamsSize = 9
+
+func (p kdfParams) MarshalBinary() ([]byte, error) {
+	b := make([]byte, kdfParamsSize)
+	binary.BigEndian.PutUint32(b[0:], p.Time)
+	binary.BigEndian.PutUint32(b[4:], p.Memory)
+	b[8] = p.Threads
+	return b, nil
+}
+
+func (p *kdfParams) UnmarshalBinary(b []byte) error {
+	if len(b) != kdfParamsSize {
+		return errKDFParams
+	}
+	p.Time = binary.BigEndian.Uint32(b[0:])
+	p.Memory = binary.BigEndian.Uint32(b[4:])
+	p.Threads = b[8]
+	if !p.valid() {
+		return errKDFParams
+	}
+	return nil
+}
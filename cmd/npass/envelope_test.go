@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestEnvelope(t *testing.T) {
+	e := envelope{
+		alg:    algArgon2idSecretbox,
+		params: []byte{1, 2, 3},
+		nonce:  []byte{4, 5},
+		data:   []byte("data"),
+	}
+
+	enc := e.encode()
+	if !isEnvelope(enc) {
+		t.Errorf("isEnvelope(%q) = false; want true", enc)
+	}
+
+	got, err := decodeEnvelope(enc)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(got, e) {
+		t.Errorf("decodeEnvelope() = %#v; want %#v", got, e)
+	}
+
+	if !isCurrent(enc, algArgon2idSecretbox) {
+		t.Errorf("isCurrent(%q, %d) = false; want true", enc, algArgon2idSecretbox)
+	}
+	if isCurrent(enc, algX25519SealedBox) {
+		t.Errorf("isCurrent(%q, %d) = true; want false", enc, algX25519SealedBox)
+	}
+}
+
+func TestDecodeEnvelopeFail(t *testing.T) {
+	encode := func(s string) string {
+		return base64.RawStdEncoding.EncodeToString([]byte(s))
+	}
+
+	tests := map[string]error{
+		"!":                                        errEnvelope,
+		encode("other\x00\x01\x01\x00\x00"):        errEnvelope,
+		encode(envelopeMagic):                      errEnvelope,
+		encode(envelopeMagic + "\x01\x01"):         errEnvelope,
+		encode(envelopeMagic + "\x01\x01\x02"):     errEnvelope,
+		encode(envelopeMagic + "\x01\x01\x00\x01"): errEnvelope,
+		encode(envelopeMagic + "\x02\x01\x00\x00"): errEnvelopeVersion,
+	}
+
+	for tc, want := range tests {
+		if _, err := decodeEnvelope(tc); !errors.Is(err, want) {
+			t.Errorf("decodeEnvelope(%q) err = %v; want %v", tc, err, want)
+		}
+	}
+}
+
+func TestKDFParamsBinary(t *testing.T) {
+	p := kdfParams{Time: 3, Memory: 65536, Threads: 4}
+	b, err := p.MarshalBinary()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var got kdfParams
+	if err := got.UnmarshalBinary(b); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != p {
+		t.Errorf("UnmarshalBinary() = %v; want %v", got, p)
+	}
+
+	if err := got.UnmarshalBinary(b[1:]); !errors.Is(err, errKDFParams) {
+		t.Errorf("UnmarshalBinary() err = %v; want %v", err, errKDFParams)
+	}
+	if err := got.UnmarshalBinary(make([]byte, kdfParamsSize)); !errors.Is(err, errKDFParams) {
+		t.Errorf("UnmarshalBinary() err = %v; want %v", err, errKDFParams)
+	}
+
+	// Parameters over the bounds are rejected before deriving anything
+	for _, p := range []kdfParams{
+		{Time: kdfMaxTime + 1, Memory: 65536, Threads: 4},
+		{Time: 1, Memory: kdfMaxMemory + 1, Threads: 4},
+	} {
+		b, _ := p.MarshalBinary()
+		if err := got.UnmarshalBinary(b); !errors.Is(err, errKDFParams) {
+			t.Errorf("UnmarshalBinary(%v) err = %v; want %v", p, err, errKDFParams)
+		}
+	}
+}
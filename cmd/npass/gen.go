@@ -0,0 +1,249 @@
+package main
+
+import (
+	"bufio"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// generator generates new secret values.
+type generator interface {
+	generate() (string, error)
+	entropy() float64
+}
+
+// Characters that are easily confused with each other.
+const charsetAmbiguous = "0O1lI"
+
+var charsetClasses = map[string]string{
+	"lower":   charsetLower,
+	"upper":   charsetUpper,
+	"number":  charsetNumber,
+	"special": charsetSpecial,
+}
+
+const defaultPasswordClasses = "lower,upper,number,special"
+
+var errGenerator = errors.New("invalid generator options")
+
+// passwordGenerator generates random passwords drawn uniformly from an
+// alphabet, with at least one character from each required class.
+type passwordGenerator struct {
+	rand     io.Reader
+	length   int
+	alphabet []rune
+	required [][]rune
+}
+
+var _ generator = (*passwordGenerator)(nil) // Static interface check
+
+// newPasswordGenerator builds a generator from a comma-separated list of
+// required classes, or from a custom alphabet if one is given.
+func newPasswordGenerator(length int, classes, alphabet string, noAmbiguous bool) (*passwordGenerator, error) {
+	g := &passwordGenerator{
+		rand:   rand.Reader,
+		length: length,
+	}
+
+	filter := func(s string) []rune {
+		var out []rune
+		for _, r := range s {
+			if noAmbiguous && strings.ContainsRune(charsetAmbiguous, r) {
+				continue
+			}
+			if containsRune(out, r) {
+				continue
+			}
+			out = append(out, r)
+		}
+		return out
+	}
+
+	if alphabet != "" {
+		g.alphabet = filter(alphabet)
+	} else {
+		for _, c := range strings.Split(classes, ",") {
+			cs, ok := charsetClasses[c]
+			if !ok {
+				return nil, fmt.Errorf("%w: unknown character class %q", errGenerator, c)
+			}
+			class := filter(cs)
+			g.required = append(g.required, class)
+			g.alphabet = append(g.alphabet, class...)
+		}
+	}
+
+	if len(g.alphabet) < 2 {
+		return nil, fmt.Errorf("%w: alphabet too small", errGenerator)
+	}
+	if length < 1 || length < len(g.required) {
+		return nil, fmt.Errorf("%w: invalid length %d", errGenerator, length)
+	}
+
+	return g, nil
+}
+
+func (g *passwordGenerator) generate() (string, error) {
+	out := make([]rune, g.length)
+
+	// Rejection sampling keeps the result uniform over all conforming
+	// passwords.
+	for {
+		for i := range out {
+			n, err := randIntn(g.rand, len(g.alphabet))
+			if err != nil {
+				return "", err
+			}
+			out[i] = g.alphabet[n]
+		}
+
+		if g.conforms(out) {
+			return string(out), nil
+		}
+	}
+}
+
+func (g *passwordGenerator) conforms(pass []rune) bool {
+outer:
+	for _, class := range g.required {
+		for _, r := range pass {
+			if containsRune(class, r) {
+				continue outer
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// entropy returns an upper bound on the entropy of generated passwords, in
+// bits.
+func (g *passwordGenerator) entropy() float64 {
+	return float64(g.length) * math.Log2(float64(len(g.alphabet)))
+}
+
+// passphraseGenerator generates diceware-style passphrases drawn uniformly
+// from a wordlist.
+type passphraseGenerator struct {
+	rand       io.Reader
+	words      []string
+	count      int
+	sep        string
+	capitalize bool
+}
+
+var _ generator = (*passphraseGenerator)(nil) // Static interface check
+
+var wordlistEFF = strings.Fields(wordlistEFFData)
+
+// newPassphraseGenerator builds a passphrase generator. If wordlist is
+// non-empty, words are read from that file instead of the built-in EFF
+// wordlist.
+func newPassphraseGenerator(count int, sep string, capitalize bool, wordlist string) (*passphraseGenerator, error) {
+	g := &passphraseGenerator{
+		rand:       rand.Reader,
+		words:      wordlistEFF,
+		count:      count,
+		sep:        sep,
+		capitalize: capitalize,
+	}
+
+	if wordlist != "" {
+		f, err := os.Open(wordlist)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		g.words, err = readWordlist(f)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(g.words) < 2 {
+		return nil, fmt.Errorf("%w: wordlist too small", errGenerator)
+	}
+	if count < 1 {
+		return nil, fmt.Errorf("%w: invalid word count %d", errGenerator, count)
+	}
+
+	return g, nil
+}
+
+// readWordlist reads a wordlist with one word per line. Lines in the
+// "11111<TAB>word" dice roll format are also accepted.
+func readWordlist(r io.Reader) ([]string, error) {
+	var words []string
+	seen := make(map[string]bool)
+
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		word := fields[len(fields)-1]
+		if seen[word] {
+			continue
+		}
+		seen[word] = true
+		words = append(words, word)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+
+	return words, nil
+}
+
+func (g *passphraseGenerator) generate() (string, error) {
+	out := make([]string, g.count)
+	for i := range out {
+		n, err := randIntn(g.rand, len(g.words))
+		if err != nil {
+			return "", err
+		}
+
+		word := g.words[n]
+		if g.capitalize {
+			r, size := utf8.DecodeRuneInString(word)
+			word = string(unicode.ToUpper(r)) + word[size:]
+		}
+		out[i] = word
+	}
+
+	return strings.Join(out, g.sep), nil
+}
+
+// entropy returns the entropy of generated passphrases, in bits.
+func (g *passphraseGenerator) entropy() float64 {
+	return float64(g.count) * math.Log2(float64(len(g.words)))
+}
+
+// randIntn returns a uniform random integer in [0, n).
+func randIntn(r io.Reader, n int) (int, error) {
+	v, err := rand.Int(r, big.NewInt(int64(n)))
+	if err != nil {
+		return 0, err
+	}
+	return int(v.Int64()), nil
+}
+
+func containsRune(rs []rune, r rune) bool {
+	for _, r1 := range rs {
+		if r1 == r {
+			return true
+		}
+	}
+	return false
+}
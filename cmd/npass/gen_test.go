@@ -0,0 +1,181 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"math"
+	"os"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestPasswordGenerator(t *testing.T) {
+	g, err := newPasswordGenerator(8, defaultPasswordClasses, "", false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for i := 0; i < 100; i++ {
+		pass, err := g.generate()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if len(pass) != 8 {
+			t.Errorf("len(%q) = %d; want %d", pass, len(pass), 8)
+		}
+		for _, cs := range []string{charsetLower, charsetUpper, charsetNumber, charsetSpecial} {
+			if !strings.ContainsAny(pass, cs) {
+				t.Errorf("%q does not contain any of %q", pass, cs)
+			}
+		}
+	}
+
+	want := 8 * math.Log2(float64(len(charsetAlnum+charsetSpecial)))
+	if got := g.entropy(); got != want {
+		t.Errorf("entropy() = %f; want %f", got, want)
+	}
+}
+
+func TestPasswordGeneratorNoAmbiguous(t *testing.T) {
+	g, err := newPasswordGenerator(64, "upper,number", "", true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for i := 0; i < 100; i++ {
+		pass, err := g.generate()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if strings.ContainsAny(pass, charsetAmbiguous) {
+			t.Errorf("%q contains ambiguous characters", pass)
+		}
+		if !containsOnly(pass, charsetUpper+charsetNumber) {
+			t.Errorf("%q contains characters outside of classes", pass)
+		}
+	}
+}
+
+func TestPasswordGeneratorCharset(t *testing.T) {
+	g, err := newPasswordGenerator(16, "invalid", "한글ab", false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	pass, err := g.generate()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n := utf8.RuneCountInString(pass); n != 16 {
+		t.Errorf("rune count of %q = %d; want %d", pass, n, 16)
+	}
+	if !containsOnly(pass, "한글ab") {
+		t.Errorf("%q contains characters outside of charset", pass)
+	}
+}
+
+func TestPasswordGeneratorFail(t *testing.T) {
+	type testCase struct {
+		length           int
+		classes, charset string
+	}
+	tests := []testCase{
+		{8, "invalid", ""},
+		{8, "lower", "a"},
+		{8, "lower", "aaaa"},
+		{0, "lower", ""},
+		{3, defaultPasswordClasses, ""},
+	}
+
+	for _, tc := range tests {
+		_, err := newPasswordGenerator(tc.length, tc.classes, tc.charset, false)
+		if !errors.Is(err, errGenerator) {
+			t.Errorf("newPasswordGenerator(%#v) err = %v; want %v", tc, err, errGenerator)
+		}
+	}
+}
+
+func TestWordlistEFF(t *testing.T) {
+	if len(wordlistEFF) != 7776 {
+		t.Errorf("len(wordlistEFF) = %d; want %d", len(wordlistEFF), 7776)
+	}
+}
+
+func TestPassphraseGenerator(t *testing.T) {
+	g, err := newPassphraseGenerator(5, ".", true, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	pass, err := g.generate()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	words := strings.Split(pass, ".")
+	if len(words) != 5 {
+		t.Fatalf("generate() = %q; want %d words", pass, 5)
+	}
+	for _, w := range words {
+		if !strings.ContainsAny(w[:1], charsetUpper) {
+			t.Errorf("word %q is not capitalized", w)
+		}
+	}
+
+	want := 5 * math.Log2(7776)
+	if got := g.entropy(); got != want {
+		t.Errorf("entropy() = %f; want %f", got, want)
+	}
+}
+
+func TestPassphraseGeneratorWordlist(t *testing.T) {
+	f, err := ioutil.TempFile("", "npass-test-*")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	if _, err := f.WriteString("11\tfoo\n12\tbar\n\nbar\n"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	g, err := newPassphraseGenerator(8, " ", false, f.Name())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	pass, err := g.generate()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, w := range strings.Fields(pass) {
+		if w != "foo" && w != "bar" {
+			t.Errorf("generate() = %q; want only words from wordlist", pass)
+		}
+	}
+
+	if got := g.entropy(); got != 8 {
+		t.Errorf("entropy() = %f; want %f", got, 8.0)
+	}
+}
+
+func TestPassphraseGeneratorFail(t *testing.T) {
+	_, err := newPassphraseGenerator(0, " ", false, "")
+	if !errors.Is(err, errGenerator) {
+		t.Errorf("newPassphraseGenerator err = %v; want %v", err, errGenerator)
+	}
+
+	_, err = newPassphraseGenerator(4, " ", false, os.DevNull)
+	if !errors.Is(err, errGenerator) {
+		t.Errorf("newPassphraseGenerator err = %v; want %v", err, errGenerator)
+	}
+
+	_, err = newPassphraseGenerator(4, " ", false, "testdata/none")
+	if !os.IsNotExist(err) {
+		t.Errorf("newPassphraseGenerator err = %v; want not exist", err)
+	}
+}
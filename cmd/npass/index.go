@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// Keys created with a private index store the names and types of their
+// passes as HMACs under a key derived from their private key, so the database
+// does not reveal them. Exact lookups still go through the (key_id, name,
+// type) index, and display names are sealed alongside to be listed once the
+// key is unlocked. The name of the key itself stays in cleartext, as it is
+// needed to find the key before it can be unlocked.
+
+// Display names are sealed under the identifier of their pass with this
+// suffix on the type, which cannot collide with a type as those never
+// contain ':'.
+const sealedNameSuffix = ":name"
+
+// indexKey derives the HMAC key of a private index from the private key.
+func indexKey(priv *[32]byte) ([]byte, error) {
+	key := make([]byte, 32)
+	kdf := hkdf.New(sha256.New, priv[:], nil, []byte("npass private index"))
+	if _, err := io.ReadFull(kdf, key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// indexHMAC returns the value stored in the given column for value.
+func indexHMAC(key []byte, column, value string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(column))
+	mac.Write([]byte{0})
+	mac.Write([]byte(value))
+	return base64.RawStdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// unlock decrypts the private key of k, asking for its password only the
+// first time.
+func (a *app) unlock(ctx context.Context, k *dbKey) (*[32]byte, error) {
+	if k.unlocked != nil {
+		return k.unlocked, nil
+	}
+
+	priv, password, err := a.unlockKey(ctx, k.name, k.priv)
+	if err != nil {
+		return nil, err
+	}
+	k.unlocked, k.password = priv, password
+	return priv, nil
+}
+
+// lock forgets the decrypted private key and password of k, overwriting the
+// key.
+func (k *dbKey) lock() {
+	if k.unlocked != nil {
+		*k.unlocked = [32]byte{}
+	}
+	k.unlocked, k.password = nil, ""
+}
+
+// openIndex unlocks k if it has a private index, so that its passes can be
+// looked up by name.
+func (a *app) openIndex(ctx context.Context, k *dbKey) error {
+	if !k.privateIndex {
+		return nil
+	}
+	_, err := a.unlock(ctx, k)
+	return err
+}
+
+// passIndex returns the values stored in the name and type columns for a
+// pass under k. Private indexes must have been opened.
+func (k dbKey) passIndex(name, typ string) (string, string, error) {
+	if !k.privateIndex {
+		return name, typ, nil
+	}
+	if k.unlocked == nil {
+		return "", "", fmt.Errorf("private index of key %q is locked", k.name)
+	}
+
+	key, err := indexKey(k.unlocked)
+	if err != nil {
+		return "", "", err
+	}
+
+	var idxName, idxType string
+	if name != "" {
+		idxName = indexHMAC(key, "name", name)
+	}
+	if typ != "" {
+		idxType = indexHMAC(key, "type", typ)
+	}
+	return idxName, idxType, nil
+}
+
+// sealName returns the sealed display name of a pass under k with a
+// private index, or NULL otherwise.
+func (k dbKey) sealName(name, typ, idxName, idxType string) (sql.NullString, error) {
+	if !k.privateIndex {
+		return sql.NullString{}, nil
+	}
+
+	pub, err := decodePubKey(k.pub)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+	enc, err := sealPass(pub, k.name, idxName, idxType+sealedNameSuffix, []byte(name+":"+typ), k.padding)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+	return sql.NullString{String: enc, Valid: true}, nil
+}
+
+// passName returns the name and type of a pass under k from the values
+// stored in its row. Private indexes must have been opened.
+func (k dbKey) passName(idxName, idxType string, sealed sql.NullString) (string, string, error) {
+	if !k.privateIndex {
+		return idxName, idxType, nil
+	}
+	if k.unlocked == nil {
+		return "", "", fmt.Errorf("private index of key %q is locked", k.name)
+	}
+
+	pub, err := decodePubKey(k.pub)
+	if err != nil {
+		return "", "", err
+	}
+	dec, err := openPass(pub, k.unlocked, k.name, idxName, idxType+sealedNameSuffix, sealed.String)
+	if err != nil {
+		return "", "", err
+	}
+
+	split := strings.SplitN(string(dec), ":", 2)
+	if len(split) != 2 || split[0] == "" || split[1] == "" ||
+		!containsOnly(split[0], charsetName) || !containsOnly(split[1], charsetType) {
+		return "", "", errDecrypt
+	}
+	return split[0], split[1], nil
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// testPrivateIndex creates the key test-p with a private index, holding the
+// passes test-p:a:pass and test-p:b:pass.
+func testPrivateIndex(t *testing.T, app *app) dbKey {
+	ctx := context.Background()
+
+	for _, args := range [][]string{
+		{"new", "-private-index", "test-p"},
+		{"new", "test-p:b:pass"},
+		{"new", "test-p:a:pass"},
+	} {
+		if err := app.run(ctx, args); err != nil {
+			t.Fatalf("%v: unexpected error: %v", args, err)
+		}
+	}
+
+	tx, err := app.st.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	k, err := queryKey(tx, "test-p")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !k.privateIndex {
+		t.Fatalf("key %q has no private index", "test-p")
+	}
+	return k
+}
+
+func TestPassIndex(t *testing.T) {
+	ctx := context.Background()
+	app, _ := testNewApp(t, &testPinentry{pass: "pass"})
+	k := testPrivateIndex(t, app)
+
+	plain := dbKey{name: "test-1"}
+	if name, typ, err := plain.passIndex("a", "pass"); err != nil || name != "a" || typ != "pass" {
+		t.Errorf("passIndex() = %q, %q, %v; want %q, %q, %v", name, typ, err, "a", "pass", nil)
+	}
+
+	_, _, err := k.passIndex("a", "pass")
+	if want := fmt.Errorf("private index of key %q is locked", "test-p"); !reflect.DeepEqual(err, want) {
+		t.Errorf("passIndex() err = %v; want %v", err, want)
+	}
+
+	if err := app.openIndex(ctx, &k); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	name, typ, err := k.passIndex("a", "pass")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if name == "a" || typ == "pass" {
+		t.Errorf("passIndex() = %q, %q; want HMACs", name, typ)
+	}
+	// Names and types are keyed apart, so equal values do not collide
+	if other, _, _ := k.passIndex("pass", ""); other == typ {
+		t.Errorf("passIndex() name and type HMACs of %q are equal", "pass")
+	}
+
+	rows, err := app.st.Query(`SELECT name, type, sealed_name FROM pass WHERE key_id = ? ORDER BY id`, k.id)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer rows.Close()
+
+	var got []string
+	for rows.Next() {
+		var p dbPass
+		if err := rows.Scan(&p.idxName, &p.idxType, &p.sealedName); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if p.idxName == "a" || p.idxName == "b" || p.idxType == "pass" || !isEnvelope(p.sealedName.String) {
+			t.Errorf("pass row %q, %q, %q leaks its name", p.idxName, p.idxType, p.sealedName.String)
+		}
+		n, ty, err := k.passName(p.idxName, p.idxType, p.sealedName)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		got = append(got, n+":"+ty)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []string{"b:pass", "a:pass"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("passName() = %q; want %q", got, want)
+	}
+
+	// Sealed names are bound to their row
+	if _, _, err := k.passName(name, typ+"x", sealedNameOf(t, app, k, name)); !errors.Is(err, errDecrypt) {
+		t.Errorf("passName() err = %v; want %v", err, errDecrypt)
+	}
+}
+
+func sealedNameOf(t *testing.T, app *app, k dbKey, idxName string) sql.NullString {
+	t.Helper()
+	var sealed sql.NullString
+	err := app.st.QueryRow(`SELECT sealed_name FROM pass WHERE key_id = ? AND name = ?`, k.id, idxName).Scan(&sealed)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return sealed
+}
+
+func TestCmdPrivateIndex(t *testing.T) {
+	ctx := context.Background()
+	pin := &testPinentry{pass: "pass", confirm: true}
+	app, out := testNewApp(t, pin)
+	k := testPrivateIndex(t, app)
+
+	now := time.Date(2020, 1, 1, 12, 0, 0, 0, time.Local)
+	testTimeNow(t, now)
+
+	tests := []struct {
+		args []string
+		out  string
+	}{
+		{[]string{"show", "test-p"}, fmt.Sprintf("test-p %s:\n  a: [pass]\n  b: [pass]\n", k.pub)},
+		{[]string{"show", "test-p:a"}, "test-p:a:pass\n"},
+		{[]string{"show", "test-p:a:pass"}, "pass\n"},
+		{[]string{"find", "a"}, "test-p:a:pass\n"},
+		{[]string{"find", "-glob", "-key", "test-p", "[ab]"}, "test-p:a:pass\ntest-p:b:pass\n"},
+		{[]string{"mv", "test-p:a", "test-p:c"}, "moved pass \"test-p:a:pass\" to \"test-p:c:pass\"\n"},
+		{[]string{"show", "test-p:c:pass"}, "pass\n"},
+		{[]string{"rm", "test-p:b:pass"}, "moved pass \"test-p:b:pass\" to trash\n"},
+		{[]string{"trash"}, "test-p:b:pass (trashed 2020-01-01 12:00)\n"},
+		{[]string{"restore", "test-p:b"}, "restored pass \"test-p:b\"\n"},
+		{[]string{"mv", "test-p:b", "test-1:moved"}, "moved pass \"test-p:b:pass\" to \"test-1:moved:pass\"\n"},
+	}
+
+	for _, tt := range tests {
+		out.Reset()
+		err := app.run(ctx, tt.args)
+		if err != nil {
+			t.Fatalf("%v: unexpected error: %v", tt.args, err)
+		}
+		if out.String() != tt.out {
+			t.Errorf("%v out = %q; want %q", tt.args, out.String(), tt.out)
+		}
+	}
+
+	pin.pass = "pass-1"
+	out.Reset()
+	if err := app.run(ctx, []string{"show", "test-1:moved:pass"}); err != nil {
+		t.Fatalf("show: unexpected error: %v", err)
+	}
+	if want := "pass\n"; out.String() != want {
+		t.Errorf("show out = %q; want %q", out.String(), want)
+	}
+
+	pin.pass = "pass"
+	if err := app.run(ctx, []string{"rotate", "test-p"}); err != nil {
+		t.Fatalf("rotate: unexpected error: %v", err)
+	}
+	out.Reset()
+	if err := app.run(ctx, []string{"show", "test-p:c:pass"}); err != nil {
+		t.Fatalf("show: unexpected error: %v", err)
+	}
+	if want := "pass\n"; out.String() != want {
+		t.Errorf("show out = %q; want %q", out.String(), want)
+	}
+}
+
+func TestCmdPrivateIndexFail(t *testing.T) {
+	ctx := context.Background()
+	app, _ := testNewApp(t, &testPinentry{pass: "pass"})
+	testPrivateIndex(t, app)
+
+	tests := []struct {
+		args []string
+		err  error
+	}{
+		{[]string{"new", "test-p:a:pass"}, fmt.Errorf("duplicate pass %q", "test-p:a:pass")},
+		{[]string{"show", "test-p:none"}, fmt.Errorf("non-existent pass %q", "test-p:none")},
+		{[]string{"show", "test-p:a:note"}, fmt.Errorf("non-existent pass %q", "test-p:a:note")},
+		{[]string{"tag", "test-p:a:pass", "tag"}, fmt.Errorf("key %q has a private index, which does not support tags", "test-p")},
+		{[]string{"new", "-private-index", "test-1:x:pass"}, errUsage},
+	}
+
+	for _, tt := range tests {
+		err := app.run(ctx, tt.args)
+		if !reflect.DeepEqual(err, tt.err) && !errors.Is(err, tt.err) {
+			t.Errorf("%v err = %v; want %v", tt.args, err, tt.err)
+		}
+	}
+
+	// A wrong password must not reveal which names exist
+	app.pin = &testPinentry{pass: "wrong"}
+	if err := app.run(ctx, []string{"show", "test-p:a:pass"}); !errors.Is(err, errTestPinentryVerify) {
+		t.Errorf("show err = %v; want %v", err, errTestPinentryVerify)
+	}
+}
+
+func TestKeyLock(t *testing.T) {
+	ctx := context.Background()
+	app, _ := testNewApp(t, &testPinentry{pass: "pass-1"})
+
+	tx, err := app.st.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	k, err := queryKey(tx, "test-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	priv, err := app.unlock(ctx, &k)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	k.lock()
+	if k.unlocked != nil || k.password != "" {
+		t.Errorf("lock() left key unlocked")
+	}
+	if *priv != [32]byte{} {
+		t.Errorf("lock() did not overwrite the private key")
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"crypto/rand"
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// kdfParams are the Argon2id parameters used to derive a key from its
+// password. Memory is in KiB.
+type kdfParams struct {
+	Time    uint32
+	Memory  uint32
+	Threads uint8
+}
+
+const kdfAlgorithm = "argon2id"
+
+var (
+	// Parameters of newly created keys.
+	defaultKDF = kdfParams{Time: 1, Memory: 6 << 20, Threads: 8}
+	// Parameters of keys created before they were stored in keys.private.
+	legacyKDF = kdfParams{Time: 1, Memory: 6 << 20, Threads: 8}
+)
+
+// Upper bounds of the parameters, so that a corrupted or crafted header
+// cannot make unlocking run out of memory or hang. Memory is in KiB.
+const (
+	kdfMaxTime   = 1024
+	kdfMaxMemory = 16 << 20
+)
+
+var errKDFParams = errors.New("invalid KDF parameters")
+
+func (p kdfParams) String() string {
+	return fmt.Sprintf("%s (time %d, memory %d KiB, threads %d)", kdfAlgorithm, p.Time, p.Memory, p.Threads)
+}
+
+func (p kdfParams) valid() bool {
+	return p.Time >= 1 && p.Time <= kdfMaxTime &&
+		p.Threads >= 1 && p.Memory >= 8*uint32(p.Threads) && p.Memory <= kdfMaxMemory
+}
+
+// header returns the prefix of keys.private values sealed with p, in the
+// style of the PHC string format. Legacy values are plain base64, which never
+// contains '$'.
+func (p kdfParams) header() string {
+	return fmt.Sprintf("$%s$v=19$m=%d,t=%d,p=%d$", kdfAlgorithm, p.Memory, p.Time, p.Threads)
+}
+
+// parseKDFHeader splits an encoded keys.private value into its KDF parameters
+// and the remaining base64 data. Values without a header use legacyKDF.
+func parseKDFHeader(privEnc string) (kdfParams, string, error) {
+	if !strings.HasPrefix(privEnc, "$") {
+		return legacyKDF, privEnc, nil
+	}
+
+	split := strings.SplitN(privEnc, "$", 5)
+	if len(split) != 5 || split[1] != kdfAlgorithm || split[2] != "v=19" {
+		return kdfParams{}, "", errKDFParams
+	}
+
+	var p kdfParams
+	if _, err := fmt.Sscanf(split[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Time, &p.Threads); err != nil {
+		return kdfParams{}, "", errKDFParams
+	}
+	// Reject anything that does not round-trip, such as trailing garbage.
+	if !p.valid() || p.header() != privEnc[:len(privEnc)-len(split[4])] {
+		return kdfParams{}, "", errKDFParams
+	}
+
+	return p, split[4], nil
+}
+
+// Bounds of the memory tried when calibrating, in KiB.
+const (
+	calibrateMinMemory = 64 << 10
+	calibrateMaxMemory = 1 << 20
+)
+
+// calibrateKDF picks parameters such that measure(p) takes about target.
+// Memory is raised first, up to maxMemory, as it is the costlier resource to
+// attackers, and then time.
+func calibrateKDF(target time.Duration, maxMemory uint32, threads uint8, measure func(kdfParams) time.Duration) kdfParams {
+	p := kdfParams{Time: 1, Memory: calibrateMinMemory, Threads: threads}
+	if p.Memory > maxMemory {
+		p.Memory = maxMemory
+	}
+	if min := 8 * uint32(threads); p.Memory < min {
+		p.Memory = min
+	}
+
+	// Cost is roughly linear in both memory and time, but measure again
+	// after each step as caches and allocation skew it.
+	d := measure(p)
+	for i := 0; i < 8 && d < target && p.Memory < maxMemory; i++ {
+		mem := scaleKDF(p.Memory, target, d)
+		if mem > maxMemory {
+			mem = maxMemory
+		}
+		if mem <= p.Memory {
+			break
+		}
+		p.Memory = mem
+		d = measure(p)
+	}
+
+	if d < target {
+		p.Time = scaleKDF(p.Time, target, d)
+		if p.Time > kdfMaxTime {
+			p.Time = kdfMaxTime
+		}
+	}
+	return p
+}
+
+// scaleKDF scales a linear cost parameter v, measured at d, to target.
+func scaleKDF(v uint32, target, d time.Duration) uint32 {
+	if d <= 0 {
+		d = 1
+	}
+	scaled := math.Ceil(float64(v) * float64(target) / float64(d))
+	if scaled > math.MaxUint32 {
+		return math.MaxUint32
+	}
+	return uint32(scaled)
+}
+
+// measureKDF returns how long deriving a key with p takes.
+func measureKDF(p kdfParams) time.Duration {
+	salt := make([]byte, saltSize)
+	_, _ = rand.Read(salt)
+
+	start := time.Now()
+	passKey("", salt, p)
+	return time.Since(start)
+}
+
+// kdfFlags are the options selecting the KDF parameters of a key.
+type kdfFlags struct {
+	target  *time.Duration
+	time    *uint
+	memory  *uint
+	threads *uint
+}
+
+func addKDFFlags(fs *flag.FlagSet) kdfFlags {
+	return kdfFlags{
+		target:  fs.Duration("kdf-target", 0, "calibrate the KDF to take about this long to unlock the key"),
+		time:    fs.Uint("kdf-time", 0, "number of KDF iterations"),
+		memory:  fs.Uint("kdf-memory", 0, "KDF memory in MiB, or its maximum when calibrating"),
+		threads: fs.Uint("kdf-threads", 0, "number of KDF threads"),
+	}
+}
+
+// set reports whether any KDF option was given.
+func (f kdfFlags) set() bool {
+	return *f.target != 0 || *f.time != 0 || *f.memory != 0 || *f.threads != 0
+}
+
+// params returns the parameters selected by the options, falling back to
+// base for those not given, and whether they were calibrated.
+func (f kdfFlags) params(base kdfParams) (kdfParams, bool, error) {
+	if *f.target < 0 || (*f.target != 0 && *f.time != 0) ||
+		*f.time > math.MaxUint32 || *f.memory > math.MaxUint32>>10 || *f.threads > math.MaxUint8 {
+		return kdfParams{}, false, errUsage
+	}
+
+	p := base
+	if *f.threads != 0 {
+		p.Threads = uint8(*f.threads)
+	}
+
+	if *f.target != 0 {
+		maxMemory := uint32(calibrateMaxMemory)
+		if *f.memory != 0 {
+			maxMemory = uint32(*f.memory) << 10
+		}
+		if maxMemory > kdfMaxMemory {
+			return kdfParams{}, false, errKDFParams
+		}
+		return calibrateKDF(*f.target, maxMemory, p.Threads, measureKDF), true, nil
+	}
+
+	if *f.time != 0 {
+		p.Time = uint32(*f.time)
+	}
+	if *f.memory != 0 {
+		p.Memory = uint32(*f.memory) << 10
+	}
+	if !p.valid() {
+		return kdfParams{}, false, errKDFParams
+	}
+	return p, false, nil
+}
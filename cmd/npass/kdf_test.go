@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// testKDF switches to cheap KDF parameters for the duration of the test. The
+// keys in the test fixture are sealed with these.
+func testKDF(t *testing.T) {
+	oldDefault, oldLegacy := defaultKDF, legacyKDF
+	defaultKDF = kdfParams{Time: 1, Memory: 32, Threads: 1}
+	legacyKDF = defaultKDF
+	t.Cleanup(func() { defaultKDF, legacyKDF = oldDefault, oldLegacy })
+}
+
+func TestParseKDFHeader(t *testing.T) {
+	testKDF(t)
+
+	p := kdfParams{Time: 3, Memory: 65536, Threads: 4}
+	got, data, err := parseKDFHeader(p.header() + "AAAA")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != p || data != "AAAA" {
+		t.Errorf("parseKDFHeader() = %v, %q; want %v, %q", got, data, p, "AAAA")
+	}
+
+	got, data, err = parseKDFHeader("AAAA")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != legacyKDF || data != "AAAA" {
+		t.Errorf("parseKDFHeader() = %v, %q; want %v, %q", got, data, legacyKDF, "AAAA")
+	}
+
+	for _, tc := range []string{
+		"$",
+		"$argon2i$v=19$m=65536,t=3,p=4$AAAA",
+		"$argon2id$v=16$m=65536,t=3,p=4$AAAA",
+		"$argon2id$v=19$m=65536,t=3$AAAA",
+		"$argon2id$v=19$m=65536,t=3,p=4x$AAAA",
+		"$argon2id$v=19$m=065536,t=3,p=4$AAAA",
+		"$argon2id$v=19$m=65536,t=0,p=4$AAAA",
+		"$argon2id$v=19$m=16,t=1,p=4$AAAA",
+		"$argon2id$v=19$m=4294967295,t=1,p=4$AAAA",
+		"$argon2id$v=19$m=16777217,t=1,p=4$AAAA",
+		"$argon2id$v=19$m=65536,t=4294967295,p=4$AAAA",
+		"$argon2id$v=19$m=65536,t=1025,p=4$AAAA",
+	} {
+		if _, _, err := parseKDFHeader(tc); !errors.Is(err, errKDFParams) {
+			t.Errorf("parseKDFHeader(%q) err = %v; want %v", tc, err, errKDFParams)
+		}
+	}
+}
+
+func TestCalibrateKDF(t *testing.T) {
+	// 1ms per MiB and iteration
+	measure := func(p kdfParams) time.Duration {
+		return time.Duration(p.Time) * time.Duration(p.Memory>>10) * time.Millisecond
+	}
+
+	tests := []struct {
+		target    time.Duration
+		maxMemory uint32
+		want      kdfParams
+	}{
+		{10 * time.Millisecond, 1 << 20, kdfParams{Time: 1, Memory: 64 << 10, Threads: 4}},
+		{time.Second, 1 << 20, kdfParams{Time: 1, Memory: 1000 << 10, Threads: 4}},
+		{time.Second, 256 << 10, kdfParams{Time: 4, Memory: 256 << 10, Threads: 4}},
+		{time.Second, 16 << 10, kdfParams{Time: 63, Memory: 16 << 10, Threads: 4}},
+		{time.Hour, 16 << 10, kdfParams{Time: kdfMaxTime, Memory: 16 << 10, Threads: 4}},
+	}
+
+	for _, tc := range tests {
+		got := calibrateKDF(tc.target, tc.maxMemory, 4, measure)
+		if got != tc.want {
+			t.Errorf("calibrateKDF(%v, %d) = %v; want %v", tc.target, tc.maxMemory, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/hkdf"
+	"golang.org/x/crypto/nacl/box"
+	"golang.org/x/crypto/nacl/secretbox"
+)
+
+const saltSize = 16
+
+var errDecrypt = errors.New("decryption error")
+
+// sealPrivKey encrypts priv under pass with a fresh salt and the KDF
+// parameters p, returning the encoded envelope stored in keys.private.
+func sealPrivKey(pass string, priv *[32]byte, p kdfParams) (string, error) {
+	params, err := p.MarshalBinary()
+	if err != nil {
+		return "", err
+	}
+
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	var nonce [24]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return "", err
+	}
+
+	pkey := passKey(pass, salt, p)
+	var keyArr [32]byte
+	copy(keyArr[:], pkey)
+
+	return envelope{
+		alg:    algKey,
+		params: append(params, salt...),
+		nonce:  nonce[:],
+		data:   secretbox.Seal(nil, priv[:], &nonce, &keyArr),
+	}.encode(), nil
+}
+
+// openPrivKey decrypts an encoded keys.private value with pass.
+func openPrivKey(pass string, privEnc string) (*[32]byte, bool) {
+	if !isEnvelope(privEnc) {
+		return openPrivKeyLegacy(pass, privEnc)
+	}
+
+	e, err := decodeEnvelope(privEnc)
+	if err != nil || e.alg != algArgon2idSecretbox ||
+		len(e.params) != kdfParamsSize+saltSize || len(e.nonce) != 24 {
+		return nil, false
+	}
+	var p kdfParams
+	if err := p.UnmarshalBinary(e.params[:kdfParamsSize]); err != nil {
+		return nil, false
+	}
+
+	pkey := passKey(pass, e.params[kdfParamsSize:], p)
+	var keyArr [32]byte
+	copy(keyArr[:], pkey)
+	var nonce [24]byte
+	copy(nonce[:], e.nonce)
+
+	dec, ok := secretbox.Open(nil, e.data, &nonce, &keyArr)
+	if !ok || len(dec) != 32 {
+		return nil, false
+	}
+
+	var priv [32]byte
+	copy(priv[:], dec)
+	return &priv, true
+}
+
+// openPrivKeyLegacy decrypts a keys.private value written before envelopes:
+// the salt and a secretbox with an all-zero nonce, optionally prefixed by a
+// KDF header.
+func openPrivKeyLegacy(pass string, privEnc string) (*[32]byte, bool) {
+	p, data, err := parseKDFHeader(privEnc)
+	if err != nil {
+		return nil, false
+	}
+	raw, err := base64.RawStdEncoding.DecodeString(data)
+	if err != nil || len(raw) < saltSize {
+		return nil, false
+	}
+
+	pkey := passKey(pass, raw[:saltSize], p)
+	var keyArr [32]byte
+	copy(keyArr[:], pkey)
+
+	dec, ok := secretbox.Open(nil, raw[saltSize:], &[24]byte{}, &keyArr)
+	if !ok || len(dec) != 32 {
+		return nil, false
+	}
+
+	var priv [32]byte
+	copy(priv[:], dec)
+	return &priv, true
+}
+
+// privKeyParams returns the KDF parameters of an encoded keys.private value.
+func privKeyParams(privEnc string) (kdfParams, error) {
+	if !isEnvelope(privEnc) {
+		p, _, err := parseKDFHeader(privEnc)
+		return p, err
+	}
+
+	e, err := decodeEnvelope(privEnc)
+	if err != nil {
+		return kdfParams{}, err
+	}
+	if e.alg != algArgon2idSecretbox || len(e.params) != kdfParamsSize+saltSize {
+		return kdfParams{}, errEnvelope
+	}
+	var p kdfParams
+	err = p.UnmarshalBinary(e.params[:kdfParamsSize])
+	return p, err
+}
+
+// unlockKey asks for the password of key and decrypts its private key.
+func (a *app) unlockKey(ctx context.Context, key, privEnc string) (*[32]byte, string, error) {
+	var priv *[32]byte
+	pass, err := a.pin.AskPass(ctx, fmt.Sprintf("Enter password for key %q:", key),
+		func(pass string) bool {
+			dec, ok := openPrivKey(pass, privEnc)
+			if !ok {
+				return false
+			}
+
+			priv = dec
+			return true
+		},
+	)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return priv, pass, nil
+}
+
+// decodePubKey decodes an encoded keys.public value.
+func decodePubKey(pubEnc string) (*[32]byte, error) {
+	raw, err := base64.RawStdEncoding.DecodeString(pubEnc)
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) != 32 {
+		return nil, fmt.Errorf("invalid public key")
+	}
+
+	var pub [32]byte
+	copy(pub[:], raw)
+	return &pub, nil
+}
+
+// passAD returns the associated data of a pass sealed in the envelope e to
+// pub: the envelope header, the recipient and the identifier, each length
+// prefixed so that no two identifiers share an encoding.
+func passAD(e envelope, pub *[32]byte, key, name, typ string) []byte {
+	ad := e.header()
+	for _, field := range [][]byte{pub[:], []byte(key), []byte(name), []byte(typ)} {
+		ad = appendUvarint(ad, uint64(len(field)))
+		ad = append(ad, field...)
+	}
+	return ad
+}
+
+func appendUvarint(b []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	return append(b, buf[:binary.PutUvarint(buf[:], v)]...)
+}
+
+// passAEAD derives the cipher of a pass from the X25519 shared secret of
+// an ephemeral key and the recipient pub.
+func passAEAD(shared []byte, epub []byte, pub *[32]byte) (cipher.AEAD, error) {
+	salt := append(append([]byte{}, epub...), pub[:]...)
+	key := make([]byte, chacha20poly1305.KeySize)
+	kdf := hkdf.New(sha256.New, shared, salt, []byte("npass x25519-xchacha20poly1305"))
+	if _, err := io.ReadFull(kdf, key); err != nil {
+		return nil, err
+	}
+	return chacha20poly1305.NewX(key)
+}
+
+// sealPass encrypts the marshaled pass data to pub, bound to its full
+// identifier, returning the encoded envelope stored in pass.data. The data is
+// padded to at least padding bytes, unless it is 0.
+func sealPass(pub *[32]byte, key, name, typ string, data []byte, padding int) (string, error) {
+	epub, epriv, err := box.GenerateKey(rand.Reader)
+	if err != nil {
+		return "", err
+	}
+	shared, err := curve25519.X25519(epriv[:], pub[:])
+	if err != nil {
+		return "", err
+	}
+	aead, err := passAEAD(shared, epub[:], pub)
+	if err != nil {
+		return "", err
+	}
+
+	e := envelope{
+		alg:    algX25519XChaCha20Poly1305,
+		params: epub[:],
+		nonce:  make([]byte, chacha20poly1305.NonceSizeX),
+	}
+	if _, err := rand.Read(e.nonce); err != nil {
+		return "", err
+	}
+	if padding != 0 {
+		e.alg = algX25519XChaCha20Poly1305Padded
+		data = pad(data, padding)
+	}
+
+	e.data = aead.Seal(nil, e.nonce, data, passAD(e, pub, key, name, typ))
+	return e.encode(), nil
+}
+
+// openPass decrypts an encoded pass.data value, checking that it was sealed
+// for the given identifier, and returns the marshaled pass data.
+func openPass(pub, priv *[32]byte, key, name, typ string, enc string) ([]byte, error) {
+	if !isEnvelope(enc) {
+		// Legacy values are the bare sealed box.
+		raw, err := base64.RawStdEncoding.DecodeString(enc)
+		if err != nil {
+			return nil, err
+		}
+		return openPassSealedBox(pub, priv, key, name, typ, raw)
+	}
+
+	e, err := decodeEnvelope(enc)
+	if err != nil {
+		return nil, err
+	}
+
+	switch e.alg {
+	case algX25519SealedBox:
+		if len(e.params) != 0 || len(e.nonce) != 0 {
+			return nil, errEnvelope
+		}
+		return openPassSealedBox(pub, priv, key, name, typ, e.data)
+	case algX25519XChaCha20Poly1305, algX25519XChaCha20Poly1305Padded:
+		if len(e.params) != 32 || len(e.nonce) != chacha20poly1305.NonceSizeX {
+			return nil, errEnvelope
+		}
+	default:
+		return nil, fmt.Errorf("%w: unexpected algorithm %d", errEnvelope, e.alg)
+	}
+
+	shared, err := curve25519.X25519(priv[:], e.params)
+	if err != nil {
+		return nil, errDecrypt
+	}
+	aead, err := passAEAD(shared, e.params, pub)
+	if err != nil {
+		return nil, err
+	}
+
+	dec, err := aead.Open(nil, e.nonce, e.data, passAD(e, pub, key, name, typ))
+	if err != nil {
+		return nil, errDecrypt
+	}
+	if e.alg == algX25519XChaCha20Poly1305Padded {
+		if dec, err = unpad(dec); err != nil {
+			return nil, errDecrypt
+		}
+	}
+	return dec, nil
+}
+
+// openPassSealedBox decrypts a sealed box whose plaintext is prefixed by the
+// identifier, as written before associated data was used.
+func openPassSealedBox(pub, priv *[32]byte, key, name, typ string, raw []byte) ([]byte, error) {
+	dec, ok := box.OpenAnonymous(nil, raw, pub, priv)
+	if !ok {
+		return nil, errDecrypt
+	}
+
+	prefix := []byte(strings.Join([]string{key, name, typ, ""}, ":"))
+	if !bytes.HasPrefix(dec, prefix) {
+		return nil, errDecrypt
+	}
+	return dec[len(prefix):], nil
+}
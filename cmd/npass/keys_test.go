@@ -0,0 +1,176 @@
+package main
+
+import (
+	"crypto/rand"
+	"errors"
+	"testing"
+
+	"golang.org/x/crypto/nacl/box"
+	"golang.org/x/crypto/poly1305"
+)
+
+func TestSealOpenPrivKey(t *testing.T) {
+	testKDF(t)
+
+	priv := &[32]byte{1, 2, 3}
+
+	enc, err := sealPrivKey("pass", priv, defaultKDF)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !isEnvelope(enc) {
+		t.Errorf("sealPrivKey() = %q; want envelope", enc)
+	}
+	if p, err := privKeyParams(enc); err != nil || p != defaultKDF {
+		t.Errorf("privKeyParams() = %v, %v; want %v", p, err, defaultKDF)
+	}
+
+	got, ok := openPrivKey("pass", enc)
+	if !ok {
+		t.Fatalf("openPrivKey() failed")
+	}
+	if *got != *priv {
+		t.Errorf("openPrivKey() = %v; want %v", got, priv)
+	}
+
+	if _, ok := openPrivKey("incorrect", enc); ok {
+		t.Errorf("openPrivKey() succeeded with incorrect password")
+	}
+	if _, ok := openPrivKey("pass", "!"); ok {
+		t.Errorf("openPrivKey() succeeded with invalid encoding")
+	}
+}
+
+func TestOpenPrivKeyLegacy(t *testing.T) {
+	testKDF(t)
+
+	// test-1 from the test fixture, without a KDF header
+	enc := "iPKo4J3hZNL3yOHjTFez1FWaax96HPlGVG3azFMLBHrfnkV4D4WZMQ2dQaYw6n/BxmxlVSsVGMqgH1niHKP3qg"
+	if _, ok := openPrivKey("pass-1", enc); !ok {
+		t.Errorf("openPrivKey() failed")
+	}
+	if p, err := privKeyParams(enc); err != nil || p != legacyKDF {
+		t.Errorf("privKeyParams() = %v, %v; want %v", p, err, legacyKDF)
+	}
+
+	legacyKDF = kdfParams{Time: 2, Memory: 32, Threads: 1}
+	if _, ok := openPrivKey("pass-1", enc); ok {
+		t.Errorf("openPrivKey() succeeded with incorrect legacy parameters")
+	}
+}
+
+func TestDecodePubKey(t *testing.T) {
+	if _, err := decodePubKey("5M60s3mDoFQJQOkZxyn0nOo2VuKzdUJZU60j3Ymkny4"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := decodePubKey("AAAA"); err == nil {
+		t.Errorf("decodePubKey did not error; want error")
+	}
+}
+
+func TestSealOpenPass(t *testing.T) {
+	pub, priv, err := box.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	data := []byte("a:b:c\n")
+	enc, err := sealPass(pub, "key", "name", "pass", data, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !isCurrent(enc, algX25519XChaCha20Poly1305) {
+		t.Errorf("sealPass() = %q; want envelope with algorithm %d", enc, algX25519XChaCha20Poly1305)
+	}
+
+	// Without padding, the plaintext is exactly the data, the identifier is
+	// only authenticated.
+	e, err := decodeEnvelope(enc)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := len(data) + poly1305.TagSize; len(e.data) != want {
+		t.Errorf("ciphertext length = %d; want %d", len(e.data), want)
+	}
+
+	got, err := openPass(pub, priv, "key", "name", "pass", enc)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(got) != string(data) {
+		t.Errorf("openPass() = %q; want %q", got, data)
+	}
+
+	otherPub, otherPriv, err := box.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	type testCase struct {
+		pub, priv      *[32]byte
+		key, name, typ string
+	}
+	for _, tc := range []testCase{
+		{pub, priv, "other", "name", "pass"},
+		{pub, priv, "key", "other", "pass"},
+		{pub, priv, "key", "name", "note"},
+		{pub, priv, "keyname", "", "pass"},
+		{otherPub, priv, "key", "name", "pass"},
+		{pub, otherPriv, "key", "name", "pass"},
+	} {
+		_, err = openPass(tc.pub, tc.priv, tc.key, tc.name, tc.typ, enc)
+		if !errors.Is(err, errDecrypt) {
+			t.Errorf("openPass(%s:%s:%s) err = %v; want %v", tc.key, tc.name, tc.typ, err, errDecrypt)
+		}
+	}
+}
+
+func TestOpenPassSealedBox(t *testing.T) {
+	pub, priv, err := box.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	raw, err := box.SealAnonymous(nil, []byte("key:name:pass:a:b"), pub, rand.Reader)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	enc := envelope{alg: algX25519SealedBox, data: raw}.encode()
+
+	got, err := openPass(pub, priv, "key", "name", "pass", enc)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(got) != "a:b" {
+		t.Errorf("openPass() = %q; want %q", got, "a:b")
+	}
+
+	_, err = openPass(pub, priv, "key", "other", "pass", enc)
+	if !errors.Is(err, errDecrypt) {
+		t.Errorf("openPass() err = %v; want %v", err, errDecrypt)
+	}
+}
+
+func TestOpenPassLegacy(t *testing.T) {
+	testKDF(t)
+
+	// test-1 and test-1:test-1:pass from the test fixture
+	pub, err := decodePubKey("5M60s3mDoFQJQOkZxyn0nOo2VuKzdUJZU60j3Ymkny4")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	priv, ok := openPrivKey("pass-1", "iPKo4J3hZNL3yOHjTFez1FWaax96HPlGVG3azFMLBHrfnkV4D4WZMQ2dQaYw6n/BxmxlVSsVGMqgH1niHKP3qg")
+	if !ok {
+		t.Fatalf("openPrivKey() failed")
+	}
+
+	enc := "LUgYJuptQvsht2iIrKJ9tOnAPyG4V3XYKsCyda59/ly5iYCWejMYBQyN5lt0Nf6G4TelWpAAQTKDhrrfQPD3hxHUbxXsYuVdkg"
+	got, err := openPass(pub, priv, "test-1", "test-1", "pass", enc)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(got) != "pass-1" {
+		t.Errorf("openPass() = %q; want %q", got, "pass-1")
+	}
+}
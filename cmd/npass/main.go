@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/signal"
@@ -21,6 +22,12 @@ func main() {
 	defer a.Close()
 
 	if err := a.run(ctx, os.Args[1:]); err != nil {
+		var ee exitError
+		if errors.As(err, &ee) {
+			a.Close()
+			os.Exit(ee.code)
+		}
+
 		fmt.Fprintf(os.Stderr, "%s: %s\n", filepath.Base(os.Args[0]), err)
 		os.Exit(1)
 	}
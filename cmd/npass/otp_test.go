@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/base32"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestOTPKeyCode(t *testing.T) {
+	// Test vectors from RFC 4226, Appendix D
+	want := []string{
+		"755224", "287082", "359152", "969429", "338314",
+		"254676", "287922", "162583", "399871", "520489",
+	}
+
+	k := newOTPKey("hotp")
+	k.secret = []byte("12345678901234567890")
+	for i, want := range want {
+		if got := k.code(uint64(i)); got != want {
+			t.Errorf("code(%d) = %q; want %q", i, got, want)
+		}
+	}
+}
+
+func TestOTPKeyTOTP(t *testing.T) {
+	// Test vectors from RFC 6238, Appendix B
+	secrets := map[string]string{
+		"SHA1":   "12345678901234567890",
+		"SHA256": "12345678901234567890123456789012",
+		"SHA512": "1234567890123456789012345678901234567890123456789012345678901234",
+	}
+
+	type testCase struct {
+		time      int64
+		algorithm string
+	}
+	tests := map[testCase]string{
+		{59, "SHA1"}:            "94287082",
+		{59, "SHA256"}:          "46119246",
+		{59, "SHA512"}:          "90693936",
+		{1111111109, "SHA1"}:    "07081804",
+		{1111111109, "SHA256"}:  "68084774",
+		{1111111109, "SHA512"}:  "25091201",
+		{1111111111, "SHA1"}:    "14050471",
+		{1111111111, "SHA256"}:  "67062674",
+		{1111111111, "SHA512"}:  "99943326",
+		{1234567890, "SHA1"}:    "89005924",
+		{1234567890, "SHA256"}:  "91819424",
+		{1234567890, "SHA512"}:  "93441116",
+		{2000000000, "SHA1"}:    "69279037",
+		{2000000000, "SHA256"}:  "90698825",
+		{2000000000, "SHA512"}:  "38618901",
+		{20000000000, "SHA1"}:   "65353130",
+		{20000000000, "SHA256"}: "77737706",
+		{20000000000, "SHA512"}: "47863826",
+	}
+
+	for tc, want := range tests {
+		k := newOTPKey("totp")
+		k.secret = []byte(secrets[tc.algorithm])
+		k.algorithm = tc.algorithm
+		k.digits = 8
+
+		got, _ := k.totp(time.Unix(tc.time, 0))
+		if got != want {
+			t.Errorf("totp(%#v) = %q; want %q", tc, got, want)
+		}
+	}
+}
+
+func TestOTPKeyTOTPLeft(t *testing.T) {
+	k := newOTPKey("totp")
+	k.secret = []byte("12345678901234567890")
+
+	if _, left := k.totp(time.Unix(59, 0)); left != 1 {
+		t.Errorf("totp left = %d; want %d", left, 1)
+	}
+	if _, left := k.totp(time.Unix(60, 0)); left != 30 {
+		t.Errorf("totp left = %d; want %d", left, 30)
+	}
+}
+
+func TestParseOTPKey(t *testing.T) {
+	secret := base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+	k, err := parseOTPKey("totp", secret)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := newOTPKey("totp")
+	want.secret = []byte("12345678901234567890")
+	if !reflect.DeepEqual(k, want) {
+		t.Errorf("parseOTPKey() = %#v; want %#v", k, want)
+	}
+
+	uri := "otpauth://totp/Example:alice@example.com?secret=" + secret +
+		"&issuer=Example&algorithm=SHA256&digits=8&period=60"
+	k, err = parseOTPKey("totp", uri)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want.label = "Example:alice@example.com"
+	want.issuer = "Example"
+	want.algorithm = "SHA256"
+	want.digits = 8
+	want.period = 60
+	if !reflect.DeepEqual(k, want) {
+		t.Errorf("parseOTPKey() = %#v; want %#v", k, want)
+	}
+
+	k, err = parseOTPKey("totp", k.String())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(k, want) {
+		t.Errorf("parseOTPKey(String()) = %#v; want %#v", k, want)
+	}
+
+	k, err = parseOTPKey("hotp", "otpauth://hotp/label?secret="+secret+"&counter=42&period=60")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want = newOTPKey("hotp")
+	want.label = "label"
+	want.secret = []byte("12345678901234567890")
+	want.counter = 42
+	if !reflect.DeepEqual(k, want) {
+		t.Errorf("parseOTPKey() = %#v; want %#v", k, want)
+	}
+}
+
+func TestParseOTPKeyFail(t *testing.T) {
+	tests := []string{
+		"",
+		"!!!!",
+		"otpauth://hotp/label?secret=GEZDGNBV",
+		"otpauth://totp/label",
+		"otpauth://totp/label?secret=GEZDGNBV&algorithm=MD5",
+		"otpauth://totp/label?secret=GEZDGNBV&digits=4",
+		"otpauth://totp/label?secret=GEZDGNBV&period=0",
+		"otpauth://totp/%zz",
+	}
+
+	for _, tc := range tests {
+		_, err := parseOTPKey("totp", tc)
+		if !errors.Is(err, errOTP) {
+			t.Errorf("parseOTPKey(%q) err = %v; want %v", tc, err, errOTP)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/poly1305"
+)
+
+// Pass data is padded before sealing, so that its sealed length only reveals
+// a bucket: the next power of two above its length, and at least the minimum
+// set for its key. The padding is that of ISO/IEC 7816-4, a 0x80 byte followed
+// by zeros, which can always be told apart from the data. A minimum of 0
+// disables padding.
+
+const (
+	// Minimum padded size of the passes of new keys, in bytes.
+	defaultPadding = 64
+	// Upper bound of the minimum padded size, in bytes.
+	maxPadding = 1 << 20
+)
+
+var errPadding = errors.New("invalid padding")
+
+// checkPadding returns an error unless min is a valid minimum padded size.
+func checkPadding(min int) error {
+	if min < 0 || min > maxPadding || min&(min-1) != 0 {
+		return fmt.Errorf("%w %d: must be 0 or a power of two up to %d", errPadding, min, maxPadding)
+	}
+	return nil
+}
+
+// paddedSize returns the size data of length n is padded to.
+func paddedSize(n, min int) int {
+	size := 1
+	for size < n+1 || size < min {
+		size <<= 1
+	}
+	return size
+}
+
+// pad returns data padded to paddedSize.
+func pad(data []byte, min int) []byte {
+	padded := make([]byte, paddedSize(len(data), min))
+	copy(padded, data)
+	padded[len(data)] = 0x80
+	return padded
+}
+
+// unpad returns data with its padding removed.
+func unpad(padded []byte) ([]byte, error) {
+	data := bytes.TrimRight(padded, "\x00")
+	if len(data) == 0 || data[len(data)-1] != 0x80 {
+		return nil, errPadding
+	}
+	return data[:len(data)-1], nil
+}
+
+// passAlg returns the envelope algorithm passes under k are sealed with.
+func (k dbKey) passAlg() byte {
+	if k.padding == 0 {
+		return algX25519XChaCha20Poly1305
+	}
+	return algX25519XChaCha20Poly1305Padded
+}
+
+// isCurrentPass reports whether enc was sealed with the algorithm and
+// padding policy of k, which is known from its length without opening it.
+func (k dbKey) isCurrentPass(enc string) bool {
+	e, err := decodeEnvelope(enc)
+	if err != nil || e.alg != k.passAlg() {
+		return false
+	}
+	if k.padding == 0 {
+		return true
+	}
+	n := len(e.data) - poly1305.TagSize
+	return n >= k.padding && n == paddedSize(n-1, k.padding)
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"testing"
+
+	"golang.org/x/crypto/nacl/box"
+	"golang.org/x/crypto/poly1305"
+)
+
+func TestCheckPadding(t *testing.T) {
+	for _, min := range []int{0, 1, 64, maxPadding} {
+		if err := checkPadding(min); err != nil {
+			t.Errorf("checkPadding(%d) err = %v; want %v", min, err, nil)
+		}
+	}
+	for _, min := range []int{-1, 3, 100, maxPadding << 1} {
+		if err := checkPadding(min); !errors.Is(err, errPadding) {
+			t.Errorf("checkPadding(%d) err = %v; want %v", min, err, errPadding)
+		}
+	}
+}
+
+func TestPad(t *testing.T) {
+	tests := []struct {
+		n, min int
+		want   int
+	}{
+		{0, 64, 64},
+		{63, 64, 64},
+		{64, 64, 128},
+		{100, 64, 128},
+		{127, 64, 128},
+		{128, 64, 256},
+		{0, 1, 1},
+		{5, 1, 8},
+		{5, 256, 256},
+	}
+
+	for _, tc := range tests {
+		data := bytes.Repeat([]byte{'a'}, tc.n)
+		padded := pad(data, tc.min)
+		if len(padded) != tc.want {
+			t.Errorf("pad(%d bytes, %d) = %d bytes; want %d", tc.n, tc.min, len(padded), tc.want)
+		}
+		got, err := unpad(padded)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Errorf("unpad(pad(%q)) = %q", data, got)
+		}
+	}
+
+	// Data that looks like padding is kept whole
+	for _, data := range [][]byte{{0x80}, {0x80, 0}, {'a', 0}, {0, 0}} {
+		got, err := unpad(pad(data, 8))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Errorf("unpad(pad(%q)) = %q", data, got)
+		}
+	}
+
+	for _, padded := range [][]byte{{}, {0, 0}, {'a', 0}, {0x80, 'a'}} {
+		if _, err := unpad(padded); !errors.Is(err, errPadding) {
+			t.Errorf("unpad(%q) err = %v; want %v", padded, err, errPadding)
+		}
+	}
+}
+
+func TestSealOpenPassPadded(t *testing.T) {
+	pub, priv, err := box.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	k := dbKey{padding: 64}
+
+	// Lengths within a bucket cannot be told apart
+	for _, data := range []string{"", "a", "a:b:c\n", string(bytes.Repeat([]byte{'a'}, 63))} {
+		enc, err := sealPass(pub, "key", "name", "pass", []byte(data), k.padding)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !k.isCurrentPass(enc) {
+			t.Errorf("isCurrentPass(%q) = false; want true", enc)
+		}
+		if (dbKey{}).isCurrentPass(enc) || (dbKey{padding: 128}).isCurrentPass(enc) {
+			t.Errorf("isCurrentPass(%q) = true under another padding; want false", enc)
+		}
+
+		e, err := decodeEnvelope(enc)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if want := 64 + poly1305.TagSize; len(e.data) != want {
+			t.Errorf("ciphertext length of %d bytes = %d; want %d", len(data), len(e.data), want)
+		}
+
+		got, err := openPass(pub, priv, "key", "name", "pass", enc)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if string(got) != data {
+			t.Errorf("openPass() = %q; want %q", got, data)
+		}
+	}
+
+	// Unpadded passes are still readable, and outdated under padding
+	enc, err := sealPass(pub, "key", "name", "pass", []byte("a:b:c\n"), 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if k.isCurrentPass(enc) {
+		t.Errorf("isCurrentPass(%q) = true; want false", enc)
+	}
+	if got, err := openPass(pub, priv, "key", "name", "pass", enc); err != nil || string(got) != "a:b:c\n" {
+		t.Errorf("openPass() = %q, %v; want %q, %v", got, err, "a:b:c\n", nil)
+	}
+
+	// The padding flag is authenticated along with the rest of the header
+	e, err := decodeEnvelope(enc)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	e.alg = algX25519XChaCha20Poly1305Padded
+	if _, err := openPass(pub, priv, "key", "name", "pass", e.encode()); !errors.Is(err, errDecrypt) {
+		t.Errorf("openPass() err = %v; want %v", err, errDecrypt)
+	}
+}
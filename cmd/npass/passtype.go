@@ -5,6 +5,7 @@ import (
 	"encoding"
 	"errors"
 	"fmt"
+	"io"
 )
 
 var errInvalidPassType = errors.New("invalid pass type")
@@ -13,12 +14,59 @@ type passType interface {
 	encoding.TextMarshaler
 	encoding.TextUnmarshaler
 
+	// readPass reads a new value for the named pass. When editing, it is
+	// called on a pass already holding the current value.
 	readPass(context.Context, *app, string) error
 	printPass() (string, error)
 }
 
+// passGenerated is implemented by pass types whose value can be generated
+// instead of read.
+type passGenerated interface {
+	passType
+	setGenerated(string) error
+}
+
+// passSelfGenerated is implemented by pass types that generate their own
+// values, such as keys.
+type passSelfGenerated interface {
+	passType
+	generatePass() error
+}
+
+// passStateful is implemented by pass types that change as they are used,
+// such as counters. Modified passes are written back by show.
+type passStateful interface {
+	passType
+	modified() bool
+}
+
+// passOperator is implemented by pass types that support operations beyond
+// printing, selected by extra arguments to show.
+type passOperator interface {
+	passType
+	operate(op string, args []string) (string, error)
+}
+
+var errInvalidOperation = errors.New("invalid pass operation")
+
+// passWriter is implemented by pass types whose values are written out
+// exactly by show, instead of being printed as a line of text.
+type passWriter interface {
+	passType
+	writePass(io.Writer) error
+}
+
 var passTypeMap = map[string]func() passType{
-	"pass": func() passType { return new(passPassword) },
+	"env":            func() passType { return new(passEnv) },
+	"file":           func() passType { return new(passFile) },
+	"hotp":           func() passType { return new(passHOTP) },
+	"login":          func() passType { return new(passLogin) },
+	"note":           func() passType { return new(passNote) },
+	"pass":           func() passType { return new(passPassword) },
+	"recovery-codes": func() passType { return new(passRecoveryCodes) },
+	"ssh-key":        func() passType { return new(passSSHKey) },
+	"totp":           func() passType { return new(passTOTP) },
 }
 
 func newPass(typ string) (passType, error) {
@@ -40,6 +88,12 @@ func (p *passPassword) readPass(ctx context.Context, a *app, name string) error
 	*p = passPassword(pass)
 	return nil
 }
+
+func (p *passPassword) setGenerated(pass string) error {
+	*p = passPassword(pass)
+	return nil
+}
+
 func (p *passPassword) printPass() (string, error) {
 	return string(*p), nil
 }
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+const envVersion = 1
+
+var errEnv = errors.New("invalid environment")
+
+// passEnv is a set of environment variables, read and printed as
+// NAME=value lines.
+type passEnv []envVar
+
+type envVar struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type envRecord struct {
+	Version int      `json:"version"`
+	Vars    []envVar `json:"vars"`
+}
+
+// readPass reads the variables from a.r, or if it is a terminal, from an
+// editor opened on the current variables. Empty lines and lines starting
+// with # are ignored.
+func (p *passEnv) readPass(ctx context.Context, a *app, name string) error {
+	var (
+		b   []byte
+		err error
+	)
+	if isTerminal(a.r) {
+		b, err = a.editText(ctx, []byte(p.format()))
+	} else {
+		b, err = ioutil.ReadAll(a.r)
+	}
+	if err != nil {
+		return err
+	}
+
+	env, err := parseEnv(string(b))
+	if err != nil {
+		return err
+	}
+
+	*p = env
+	return nil
+}
+
+func (p *passEnv) printPass() (string, error) {
+	return p.format(), nil
+}
+
+// operate prints the value of the variable named by op.
+func (p *passEnv) operate(op string, args []string) (string, error) {
+	if len(args) != 0 {
+		return "", errInvalidOperation
+	}
+
+	for _, v := range *p {
+		if v.Name == op {
+			return v.Value, nil
+		}
+	}
+	return "", fmt.Errorf("non-existent variable %q", op)
+}
+
+// environ returns the variables in the form used by os/exec.
+func (p *passEnv) environ() []string {
+	env := make([]string, 0, len(*p))
+	for _, v := range *p {
+		env = append(env, v.Name+"="+v.Value)
+	}
+	return env
+}
+
+func (p *passEnv) MarshalText() ([]byte, error) {
+	return json.Marshal(envRecord{
+		Version: envVersion,
+		Vars:    *p,
+	})
+}
+
+func (p *passEnv) UnmarshalText(b []byte) error {
+	var rec envRecord
+	if err := json.Unmarshal(b, &rec); err != nil {
+		return fmt.Errorf("%w: %v", errEnv, err)
+	}
+	if rec.Version != envVersion {
+		return fmt.Errorf("%w: unsupported version %d", errEnv, rec.Version)
+	}
+
+	*p = rec.Vars
+	return nil
+}
+
+func (p *passEnv) format() string {
+	var sb strings.Builder
+	for _, v := range *p {
+		fmt.Fprintf(&sb, "%s=%s\n", v.Name, v.Value)
+	}
+	return sb.String()
+}
+
+// Charsets allowed in environment variable names.
+const (
+	charsetEnvStart = charsetAlpha + "_"
+	charsetEnv      = charsetAlnum + "_"
+)
+
+// parseEnv parses NAME=value lines. Later definitions of a variable replace
+// earlier ones.
+func parseEnv(s string) (passEnv, error) {
+	var env passEnv
+	index := make(map[string]int)
+
+	sc := bufio.NewScanner(strings.NewReader(s))
+	for sc.Scan() {
+		line := sc.Text()
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+
+		split := strings.SplitN(line, "=", 2)
+		if len(split) != 2 {
+			return nil, fmt.Errorf("%w: invalid line %q", errEnv, line)
+		}
+		k, v := strings.TrimSpace(split[0]), split[1]
+		if k == "" || !containsOnly(k[:1], charsetEnvStart) || !containsOnly(k, charsetEnv) {
+			return nil, fmt.Errorf("%w: invalid variable name %q", errEnv, k)
+		}
+
+		if i, ok := index[k]; ok {
+			env[i].Value = v
+			continue
+		}
+		index[k] = len(env)
+		env = append(env, envVar{Name: k, Value: v})
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(env) == 0 {
+		return nil, fmt.Errorf("%w: no variables", errEnv)
+	}
+	return env, nil
+}
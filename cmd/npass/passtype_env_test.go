@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseEnv(t *testing.T) {
+	tests := []struct {
+		in  string
+		out passEnv
+		err error
+	}{
+		{
+			in:  "# comment\nFOO=bar\n\nBAZ = a=b \nFOO=qux\n",
+			out: passEnv{{"FOO", "qux"}, {"BAZ", " a=b "}},
+		},
+		{in: "FOO=\n", out: passEnv{{"FOO", ""}}},
+		{in: "\n# empty\n", err: errEnv},
+		{in: "FOO\n", err: errEnv},
+		{in: "1FOO=bar\n", err: errEnv},
+		{in: "FOO-BAR=baz\n", err: errEnv},
+	}
+
+	for _, tt := range tests {
+		out, err := parseEnv(tt.in)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("parseEnv(%q) err = %v; want %v", tt.in, err, tt.err)
+			continue
+		}
+		if !reflect.DeepEqual(out, tt.out) {
+			t.Errorf("parseEnv(%q) = %v; want %v", tt.in, out, tt.out)
+		}
+	}
+}
+
+func TestPassEnvRead(t *testing.T) {
+	a, _ := testNewApp(t, &testPinentry{})
+	a.r = strings.NewReader("FOO=bar\nBAZ=qux\n")
+	p := new(passEnv)
+
+	err := p.readPass(context.Background(), a, "testing")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []string{"FOO=bar", "BAZ=qux"}
+	if !reflect.DeepEqual(p.environ(), want) {
+		t.Errorf("environ() = %v; want %v", p.environ(), want)
+	}
+}
+
+func TestPassEnvMarshalText(t *testing.T) {
+	p := &passEnv{{"FOO", "bar\nbaz"}}
+
+	b, err := p.MarshalText()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var p1 passEnv
+	if err := p1.UnmarshalText(b); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(p1, *p) {
+		t.Errorf("UnmarshalText returned %v; want %v", p1, *p)
+	}
+
+	if err := p1.UnmarshalText([]byte("FOO=bar")); !errors.Is(err, errEnv) {
+		t.Errorf("UnmarshalText err = %v; want %v", err, errEnv)
+	}
+}
+
+func TestPassEnvOperate(t *testing.T) {
+	p := &passEnv{{"FOO", "bar"}}
+
+	out, err := p.operate("FOO", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out != "bar" {
+		t.Errorf("operate(%q) = %q; want %q", "FOO", out, "bar")
+	}
+
+	if _, err := p.operate("BAZ", nil); err == nil {
+		t.Errorf("operate(%q) did not error", "BAZ")
+	}
+}
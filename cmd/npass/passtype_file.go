@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+const (
+	fileVersion = 1
+
+	// Default limit on the size of file passes, in bytes.
+	defaultMaxFileSize = 1 << 20
+)
+
+var errFile = errors.New("invalid file")
+
+// passFile is an arbitrary binary file, with its original filename and mode.
+//
+// Unlike other pass types, its marshaled form is not text: a JSON header line
+// is followed by the raw contents of the file.
+type passFile struct {
+	name string
+	mode os.FileMode
+	data []byte
+}
+
+type fileHeader struct {
+	Version int    `json:"version"`
+	Name    string `json:"name,omitempty"`
+	Mode    uint32 `json:"mode"`
+	Size    int    `json:"size"`
+	SHA256  string `json:"sha256"`
+}
+
+// readPass reads the file from a.r, up to a.maxFileSize bytes. If a.r is a
+// regular file other than stdin, its filename and mode are recorded.
+func (p *passFile) readPass(ctx context.Context, a *app, name string) error {
+	max := a.maxFileSize
+	if max <= 0 {
+		max = defaultMaxFileSize
+	}
+
+	b, err := ioutil.ReadAll(io.LimitReader(a.r, max+1))
+	if err != nil {
+		return err
+	}
+	if int64(len(b)) > max {
+		return fmt.Errorf("file exceeds size limit of %d bytes", max)
+	}
+
+	p.name = ""
+	p.mode = 0600
+	if f, ok := a.r.(*os.File); ok && f != os.Stdin {
+		if fi, err := f.Stat(); err == nil && fi.Mode().IsRegular() {
+			p.name = filepath.Base(f.Name())
+			p.mode = fi.Mode().Perm()
+		}
+	}
+
+	p.data = b
+	return nil
+}
+
+func (p *passFile) printPass() (string, error) {
+	return string(p.data), nil
+}
+
+// writePass writes the contents of the file to w unchanged.
+func (p *passFile) writePass(w io.Writer) error {
+	_, err := w.Write(p.data)
+	return err
+}
+
+// operate supports "info", which prints the file's metadata, and
+// "save PATH", which writes the file to PATH with its original mode.
+func (p *passFile) operate(op string, args []string) (string, error) {
+	switch {
+	case op == "info" && len(args) == 0:
+		sum := sha256.Sum256(p.data)
+		return fmt.Sprintf("name: %s\nmode: %s\nsize: %d\nsha256: %x\n",
+			p.name, p.mode, len(p.data), sum), nil
+	case op == "save" && len(args) == 1:
+		// Never overwrite existing files
+		f, err := os.OpenFile(args[0], os.O_WRONLY|os.O_CREATE|os.O_EXCL, p.mode)
+		if err != nil {
+			return "", err
+		}
+		// The mode given to OpenFile is subject to umask
+		if err := f.Chmod(p.mode); err != nil {
+			f.Close()
+			return "", err
+		}
+		if _, err := f.Write(p.data); err != nil {
+			f.Close()
+			return "", err
+		}
+		if err := f.Close(); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("wrote %d bytes to %q", len(p.data), args[0]), nil
+	}
+
+	return "", errInvalidOperation
+}
+
+func (p *passFile) MarshalText() ([]byte, error) {
+	sum := sha256.Sum256(p.data)
+	hdr, err := json.Marshal(fileHeader{
+		Version: fileVersion,
+		Name:    p.name,
+		Mode:    uint32(p.mode),
+		Size:    len(p.data),
+		SHA256:  hex.EncodeToString(sum[:]),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	b := make([]byte, 0, len(hdr)+1+len(p.data))
+	b = append(b, hdr...)
+	b = append(b, '\n')
+	return append(b, p.data...), nil
+}
+
+func (p *passFile) UnmarshalText(b []byte) error {
+	i := bytes.IndexByte(b, '\n')
+	if i < 0 {
+		return fmt.Errorf("%w: missing header", errFile)
+	}
+
+	var hdr fileHeader
+	if err := json.Unmarshal(b[:i], &hdr); err != nil {
+		return fmt.Errorf("%w: %v", errFile, err)
+	}
+	if hdr.Version != fileVersion {
+		return fmt.Errorf("%w: unsupported version %d", errFile, hdr.Version)
+	}
+
+	data := b[i+1:]
+	sum := sha256.Sum256(data)
+	if len(data) != hdr.Size || hex.EncodeToString(sum[:]) != hdr.SHA256 {
+		return fmt.Errorf("%w: checksum mismatch", errFile)
+	}
+
+	p.name = hdr.Name
+	p.mode = os.FileMode(hdr.Mode).Perm()
+	p.data = append([]byte(nil), data...)
+	return nil
+}
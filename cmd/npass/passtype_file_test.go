@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Binary contents without a trailing newline
+var testFileData = []byte("\x00\x01\xff\r\nkeytab\x00")
+
+func testFile(t *testing.T, mode os.FileMode) string {
+	dir, err := ioutil.TempDir("", "npass-test-")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	name := filepath.Join(dir, "test.keytab")
+	if err := ioutil.WriteFile(name, testFileData, mode); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := os.Chmod(name, mode); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return name
+}
+
+func TestPassFileRead(t *testing.T) {
+	a, _ := testNewApp(t, &testPinentry{})
+	done, err := a.openInput(testFile(t, 0640))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer done()
+
+	p := new(passFile)
+	if err := p.readPass(context.Background(), a, "testing"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if p.name != "test.keytab" || p.mode != 0640 || !bytes.Equal(p.data, testFileData) {
+		t.Errorf("readPass returned %q, %v, %q; want %q, %v, %q",
+			p.name, p.mode, p.data, "test.keytab", os.FileMode(0640), testFileData)
+	}
+}
+
+func TestPassFileReadStream(t *testing.T) {
+	a, _ := testNewApp(t, &testPinentry{})
+	a.r = bytes.NewReader(testFileData)
+
+	p := new(passFile)
+	if err := p.readPass(context.Background(), a, "testing"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if p.name != "" || p.mode != 0600 || !bytes.Equal(p.data, testFileData) {
+		t.Errorf("readPass returned %q, %v, %q; want %q, %v, %q",
+			p.name, p.mode, p.data, "", os.FileMode(0600), testFileData)
+	}
+}
+
+func TestPassFileReadLimit(t *testing.T) {
+	a, _ := testNewApp(t, &testPinentry{})
+	a.maxFileSize = int64(len(testFileData))
+
+	a.r = bytes.NewReader(testFileData)
+	if err := new(passFile).readPass(context.Background(), a, "testing"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	a.r = bytes.NewReader(append(testFileData, 0))
+	err := new(passFile).readPass(context.Background(), a, "testing")
+	if err == nil || !strings.Contains(err.Error(), "size limit") {
+		t.Errorf("readPass err = %v; want size limit error", err)
+	}
+}
+
+func TestPassFileMarshalText(t *testing.T) {
+	p := &passFile{name: "test.p12", mode: 0644, data: testFileData}
+
+	b, err := p.MarshalText()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var p1 passFile
+	if err := p1.UnmarshalText(b); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if p1.name != p.name || p1.mode != p.mode || !bytes.Equal(p1.data, p.data) {
+		t.Errorf("UnmarshalText returned %v; want %v", p1, p)
+	}
+
+	b[len(b)-1] ^= 1
+	if err := p1.UnmarshalText(b); !errors.Is(err, errFile) {
+		t.Errorf("UnmarshalText err = %v; want %v", err, errFile)
+	}
+}
+
+func TestCmdShowFile(t *testing.T) {
+	ctx := context.Background()
+	app, out := testNewApp(t, &testPinentry{pass: "pass-1"})
+
+	err := app.run(ctx, []string{"new", "-in", testFile(t, 0640), "test-1:keytab:file"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	out.Reset()
+	err = app.run(ctx, []string{"show", "test-1:keytab:file"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Equal(out.Bytes(), testFileData) {
+		t.Errorf("show (file) out = %q; want %q", out.Bytes(), testFileData)
+	}
+
+	dst := filepath.Join(filepath.Dir(testFile(t, 0600)), "out.keytab")
+	out.Reset()
+	err = app.run(ctx, []string{"show", "test-1:keytab:file", "save", dst})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := fmt.Sprintf("wrote %d bytes to %q\n", len(testFileData), dst); out.String() != want {
+		t.Errorf("show (file) out = %q; want %q", out.String(), want)
+	}
+
+	b, err := ioutil.ReadFile(dst)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Equal(b, testFileData) {
+		t.Errorf("saved file = %q; want %q", b, testFileData)
+	}
+	fi, err := os.Stat(dst)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if fi.Mode().Perm() != 0640 {
+		t.Errorf("saved file mode = %v; want %v", fi.Mode(), os.FileMode(0640))
+	}
+
+	// Existing files are not overwritten
+	err = app.run(ctx, []string{"show", "test-1:keytab:file", "save", dst})
+	if !errors.Is(err, os.ErrExist) {
+		t.Errorf("show save err = %v; want %v", err, os.ErrExist)
+	}
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+)
+
+const loginVersion = 1
+
+var errLogin = errors.New("invalid login")
+
+// passLogin is a structured login record. It is read and printed as
+// "field: value" lines, with free-form notes at the end.
+type passLogin loginRecord
+
+// loginRecord is the stored form of passLogin, without its TextMarshaler
+// methods.
+type loginRecord struct {
+	Version  int               `json:"version"`
+	Username string            `json:"username,omitempty"`
+	Password string            `json:"password,omitempty"`
+	URLs     []string          `json:"urls,omitempty"`
+	Fields   map[string]string `json:"fields,omitempty"`
+	Notes    string            `json:"notes,omitempty"`
+}
+
+// readPass reads the record from a.r, or if it is a terminal, from an
+// editor. The password is never written to the editor's file; if left empty,
+// the current password is kept, or a new one is asked for.
+func (p *passLogin) readPass(ctx context.Context, a *app, name string) error {
+	var (
+		b   []byte
+		err error
+	)
+	if isTerminal(a.r) {
+		b, err = a.editText(ctx, []byte(p.format(false)))
+	} else {
+		b, err = ioutil.ReadAll(a.r)
+	}
+	if err != nil {
+		return err
+	}
+
+	rec, err := parseLogin(string(b))
+	if err != nil {
+		return err
+	}
+
+	if rec.Password == "" {
+		rec.Password = p.Password
+	}
+	if rec.Password == "" {
+		rec.Password, err = a.pin.NewPass(ctx, fmt.Sprintf("Enter password for %q:", name))
+		if err != nil {
+			return err
+		}
+	}
+
+	*p = rec
+	return nil
+}
+
+func (p *passLogin) printPass() (string, error) {
+	return p.format(true), nil
+}
+
+// operate prints the single field selected by op.
+func (p *passLogin) operate(op string, args []string) (string, error) {
+	if len(args) != 0 {
+		return "", errInvalidOperation
+	}
+
+	switch op {
+	case "username":
+		return p.Username, nil
+	case "password":
+		return p.Password, nil
+	case "url":
+		return strings.Join(p.URLs, "\n"), nil
+	case "notes":
+		return p.Notes, nil
+	}
+
+	v, ok := p.Fields[op]
+	if !ok {
+		return "", fmt.Errorf("non-existent field %q", op)
+	}
+	return v, nil
+}
+
+func (p *passLogin) MarshalText() ([]byte, error) {
+	rec := loginRecord(*p)
+	rec.Version = loginVersion
+	return json.Marshal(rec)
+}
+
+func (p *passLogin) UnmarshalText(b []byte) error {
+	var rec loginRecord
+	if err := json.Unmarshal(b, &rec); err != nil {
+		return fmt.Errorf("%w: %v", errLogin, err)
+	}
+	if rec.Version != loginVersion {
+		return fmt.Errorf("%w: unsupported version %d", errLogin, rec.Version)
+	}
+
+	*p = passLogin(rec)
+	return nil
+}
+
+func (p *passLogin) format(withPassword bool) string {
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, "username: %s\n", p.Username)
+	if withPassword {
+		fmt.Fprintf(&sb, "password: %s\n", p.Password)
+	} else {
+		fmt.Fprintf(&sb, "password: \n")
+	}
+
+	if len(p.URLs) == 0 {
+		fmt.Fprintf(&sb, "url: \n")
+	}
+	for _, u := range p.URLs {
+		fmt.Fprintf(&sb, "url: %s\n", u)
+	}
+
+	fields := make([]string, 0, len(p.Fields))
+	for k := range p.Fields {
+		fields = append(fields, k)
+	}
+	sort.Strings(fields)
+	for _, k := range fields {
+		fmt.Fprintf(&sb, "%s: %s\n", k, p.Fields[k])
+	}
+
+	fmt.Fprintf(&sb, "notes:\n%s", p.Notes)
+	return sb.String()
+}
+
+// parseLogin parses a record in the format produced by format. Everything
+// after a "notes:" line is taken as notes.
+func parseLogin(s string) (passLogin, error) {
+	rec := passLogin{Version: loginVersion}
+
+	sc := bufio.NewScanner(strings.NewReader(s))
+	for sc.Scan() {
+		line := sc.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		split := strings.SplitN(line, ":", 2)
+		if len(split) != 2 {
+			return passLogin{}, fmt.Errorf("%w: invalid line %q", errLogin, line)
+		}
+		k, v := strings.TrimSpace(split[0]), strings.TrimSpace(split[1])
+
+		switch k {
+		case "username":
+			rec.Username = v
+		case "password":
+			// Passwords may begin or end with spaces, so only the space
+			// written by format is removed.
+			rec.Password = strings.TrimPrefix(split[1], " ")
+		case "url":
+			if v != "" {
+				rec.URLs = append(rec.URLs, v)
+			}
+		case "notes":
+			var notes []string
+			if v != "" {
+				notes = append(notes, v)
+			}
+			for sc.Scan() {
+				notes = append(notes, sc.Text())
+			}
+			rec.Notes = strings.TrimRight(strings.Join(notes, "\n"), "\n")
+			if rec.Notes != "" {
+				rec.Notes += "\n"
+			}
+		case "":
+			return passLogin{}, fmt.Errorf("%w: invalid line %q", errLogin, line)
+		default:
+			if rec.Fields == nil {
+				rec.Fields = make(map[string]string)
+			}
+			rec.Fields[k] = v
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return passLogin{}, err
+	}
+
+	return rec, nil
+}
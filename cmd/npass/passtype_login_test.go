@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testLoginText = `username: alice
+password: hunter2
+url: https://example.com
+url: https://login.example.com
+pin: 1234
+notes:
+recovery email is bob@example.com
+
+security question: mother's maiden name
+`
+
+var testLogin = passLogin{
+	Version:  loginVersion,
+	Username: "alice",
+	Password: "hunter2",
+	URLs:     []string{"https://example.com", "https://login.example.com"},
+	Fields:   map[string]string{"pin": "1234"},
+	Notes:    "recovery email is bob@example.com\n\nsecurity question: mother's maiden name\n",
+}
+
+func TestParseLogin(t *testing.T) {
+	got, err := parseLogin(testLoginText)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(got, testLogin) {
+		t.Errorf("parseLogin() = %#v; want %#v", got, testLogin)
+	}
+
+	if got := testLogin.format(true); got != testLoginText {
+		t.Errorf("format() = %q; want %q", got, testLoginText)
+	}
+}
+
+func TestParseLoginPasswordSpaces(t *testing.T) {
+	rec := passLogin{Version: loginVersion, Username: "alice", Password: " pw "}
+	got, err := parseLogin(rec.format(true))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.Password != rec.Password {
+		t.Errorf("parseLogin(format()) password = %q; want %q", got.Password, rec.Password)
+	}
+
+	got, err = parseLogin("password:pw\n")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.Password != "pw" {
+		t.Errorf("parseLogin() password = %q; want %q", got.Password, "pw")
+	}
+}
+
+func TestParseLoginFail(t *testing.T) {
+	for _, tc := range []string{"invalid", ": value"} {
+		_, err := parseLogin(tc)
+		if !errors.Is(err, errLogin) {
+			t.Errorf("parseLogin(%q) err = %v; want %v", tc, err, errLogin)
+		}
+	}
+}
+
+func TestPassLoginRead(t *testing.T) {
+	pin := &testPinentry{pass: "pinentry"}
+	a, _ := testNewApp(t, pin)
+	a.r = strings.NewReader("username: alice\n")
+	p := new(passLogin)
+
+	err := p.readPass(context.Background(), a, "testing")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := passLogin{Version: loginVersion, Username: "alice", Password: "pinentry"}
+	if !reflect.DeepEqual(*p, want) {
+		t.Errorf("readPass returned %#v; want %#v", *p, want)
+	}
+}
+
+func TestPassLoginReadEditor(t *testing.T) {
+	testEditor(t, `grep -q '^password: $' "$1" && printf 'username: bob\n' > "$1"`)
+
+	a, _ := testNewApp(t, &testPinentry{})
+	p := new(passLogin)
+	*p = testLogin
+
+	err := p.readPass(context.Background(), a, "testing")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := passLogin{Version: loginVersion, Username: "bob", Password: "hunter2"}
+	if !reflect.DeepEqual(*p, want) {
+		t.Errorf("readPass returned %#v; want %#v", *p, want)
+	}
+}
+
+func TestPassLoginOperate(t *testing.T) {
+	p := testLogin
+
+	tests := map[string]string{
+		"username": "alice",
+		"password": "hunter2",
+		"url":      "https://example.com\nhttps://login.example.com",
+		"pin":      "1234",
+		"notes":    testLogin.Notes,
+	}
+	for op, want := range tests {
+		got, err := p.operate(op, nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got != want {
+			t.Errorf("operate(%q) = %q; want %q", op, got, want)
+		}
+	}
+
+	_, err := p.operate("none", nil)
+	if want := fmt.Errorf("non-existent field %q", "none"); !reflect.DeepEqual(err, want) {
+		t.Errorf("operate() err = %v; want %v", err, want)
+	}
+
+	_, err = p.operate("username", []string{"extra"})
+	if !errors.Is(err, errInvalidOperation) {
+		t.Errorf("operate() err = %v; want %v", err, errInvalidOperation)
+	}
+}
+
+func TestPassLoginMarshalText(t *testing.T) {
+	b, err := testLogin.MarshalText()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var got passLogin
+	if err := got.UnmarshalText(b); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(got, testLogin) {
+		t.Errorf("UnmarshalText() = %#v; want %#v", got, testLogin)
+	}
+
+	for _, tc := range []string{"invalid", `{"version":2}`} {
+		err := got.UnmarshalText([]byte(tc))
+		if !errors.Is(err, errLogin) {
+			t.Errorf("UnmarshalText(%q) err = %v; want %v", tc, err, errLogin)
+		}
+	}
+}
+
+func TestCmdShowLogin(t *testing.T) {
+	ctx := context.Background()
+	pin := &testPinentry{pass: "pass-1"}
+	app, out := testNewApp(t, pin)
+
+	app.r = strings.NewReader(testLoginText)
+	err := app.run(ctx, []string{"new", "test-1:example:login"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	out.Reset()
+	err = app.run(ctx, []string{"show", "test-1:example:login"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out.String() != testLoginText {
+		t.Errorf("show (login) out = %q; want %q", out.String(), testLoginText)
+	}
+
+	out.Reset()
+	err = app.run(ctx, []string{"show", "test-1:example:login", "password"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "hunter2\n"; out.String() != want {
+		t.Errorf("show (login) out = %q; want %q", out.String(), want)
+	}
+}
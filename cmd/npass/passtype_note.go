@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+var errEmptyNote = errors.New("empty note")
+
+// Whether r is an interactive terminal, replaceable in tests.
+var isTerminal = func(r io.Reader) bool {
+	f, ok := r.(*os.File)
+	return ok && terminal.IsTerminal(int(f.Fd()))
+}
+
+// passNote is a free-form, possibly multi-line, note.
+type passNote string
+
+// readPass reads the note from a.r, or if it is a terminal, from an editor
+// opened on the current contents of the note.
+func (p *passNote) readPass(ctx context.Context, a *app, name string) error {
+	var (
+		b   []byte
+		err error
+	)
+	if isTerminal(a.r) {
+		b, err = a.editText(ctx, []byte(*p))
+	} else {
+		b, err = ioutil.ReadAll(a.r)
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(strings.TrimSpace(string(b))) == 0 {
+		return errEmptyNote
+	}
+
+	*p = passNote(b)
+	return nil
+}
+
+func (p *passNote) printPass() (string, error) {
+	return string(*p), nil
+}
+
+// writePass writes the note to w exactly, as notes may or may not end in a
+// newline.
+func (p *passNote) writePass(w io.Writer) error {
+	_, err := io.WriteString(w, string(*p))
+	return err
+}
+
+func (p *passNote) MarshalText() ([]byte, error) {
+	return []byte(*p), nil
+}
+
+func (p *passNote) UnmarshalText(b []byte) error {
+	*p = passNote(b)
+	return nil
+}
+
+// editText opens $EDITOR on a temporary file containing text, and returns
+// the edited contents. The file is created in a private directory, which is
+// wiped once the editor exits.
+func (a *app) editText(ctx context.Context, text []byte) ([]byte, error) {
+	dir, err := ioutil.TempDir(os.Getenv("XDG_RUNTIME_DIR"), "npass-")
+	if err != nil {
+		return nil, err
+	}
+	defer wipeDir(dir)
+
+	name := filepath.Join(dir, "note")
+	if err := ioutil.WriteFile(name, text, 0600); err != nil {
+		return nil, err
+	}
+
+	editor := strings.Fields(os.Getenv("EDITOR"))
+	if len(editor) == 0 {
+		editor = []string{"vi"}
+	}
+
+	cmd := exec.CommandContext(ctx, editor[0], append(editor[1:], name)...)
+	cmd.Stdin = a.r
+	cmd.Stdout = a.w
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return nil, err
+	}
+
+	return ioutil.ReadFile(name)
+}
+
+// wipeDir overwrites every regular file under dir with zeros, then removes
+// it. Editors may leave swap and backup files next to the edited file.
+func wipeDir(dir string) {
+	_ = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.Mode().IsRegular() {
+			return nil
+		}
+
+		f, err := os.OpenFile(path, os.O_WRONLY, 0)
+		if err != nil {
+			return nil
+		}
+		defer f.Close()
+
+		_, _ = f.Write(make([]byte, info.Size()))
+		_ = f.Sync()
+		return nil
+	})
+	_ = os.RemoveAll(dir)
+}
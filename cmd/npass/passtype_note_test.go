@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testEditor(t *testing.T, script string) {
+	dir, err := ioutil.TempDir("", "npass-test-")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	editor := filepath.Join(dir, "editor")
+	err = ioutil.WriteFile(editor, []byte("#!/bin/sh\n"+script), 0700)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	oldEditor := os.Getenv("EDITOR")
+	os.Setenv("EDITOR", editor)
+	isTerminal = func(io.Reader) bool { return true }
+	t.Cleanup(func() {
+		os.Setenv("EDITOR", oldEditor)
+		isTerminal = isTerminalDefault
+	})
+}
+
+var isTerminalDefault = isTerminal
+
+func TestPassNoteRead(t *testing.T) {
+	a, _ := testNewApp(t, &testPinentry{})
+	a.r = strings.NewReader("line 1\nline 2:\n\n")
+	p := new(passNote)
+
+	err := p.readPass(context.Background(), a, "testing")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if want := "line 1\nline 2:\n\n"; string(*p) != want {
+		t.Errorf("readPass returned %q; want %q", *p, want)
+	}
+}
+
+func TestPassNoteReadEmpty(t *testing.T) {
+	a, _ := testNewApp(t, &testPinentry{})
+	a.r = strings.NewReader(" \n")
+	p := new(passNote)
+
+	err := p.readPass(context.Background(), a, "testing")
+	if !errors.Is(err, errEmptyNote) {
+		t.Errorf("readPass err = %v; want %v", err, errEmptyNote)
+	}
+}
+
+func TestPassNoteReadEditor(t *testing.T) {
+	testEditor(t, `printf 'line 2\n' >> "$1"`)
+
+	a, _ := testNewApp(t, &testPinentry{})
+	p := new(passNote)
+	*p = "line 1\n"
+
+	err := p.readPass(context.Background(), a, "testing")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if want := "line 1\nline 2\n"; string(*p) != want {
+		t.Errorf("readPass returned %q; want %q", *p, want)
+	}
+}
+
+func TestPassNoteReadEditorFail(t *testing.T) {
+	testEditor(t, `exit 1`)
+
+	a, _ := testNewApp(t, &testPinentry{})
+	p := new(passNote)
+
+	err := p.readPass(context.Background(), a, "testing")
+	if err == nil {
+		t.Errorf("readPass did not error; want error")
+	}
+}
+
+func TestWipeDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "npass-test-")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "note"), []byte("secret"), 0600); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	wipeDir(dir)
+
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("stat err = %v; want not exist", err)
+	}
+}
+
+func TestCmdShowNote(t *testing.T) {
+	ctx := context.Background()
+	pin := &testPinentry{pass: "pass-1"}
+	app, out := testNewApp(t, pin)
+
+	note := "-----BEGIN CERTIFICATE-----\nabc:def\n-----END CERTIFICATE-----\n"
+	app.r = strings.NewReader(note)
+	err := app.run(ctx, []string{"new", "test-1:cert:note"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	out.Reset()
+	err = app.run(ctx, []string{"show", "test-1:cert:note"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out.String() != note {
+		t.Errorf("show (note) out = %q; want %q", out.String(), note)
+	}
+}
+
+func TestCmdShowNoteNewline(t *testing.T) {
+	ctx := context.Background()
+	app, out := testNewApp(t, &testPinentry{pass: "pass-1"})
+
+	// Notes are shown exactly, with or without a final newline
+	for i, note := range []string{"abc", "abc\n", "abc\n\n"} {
+		name := fmt.Sprintf("test-1:note-%d:note", i)
+		app.r = strings.NewReader(note)
+		if err := app.run(ctx, []string{"new", name}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		out.Reset()
+		if err := app.run(ctx, []string{"show", name}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if out.String() != note {
+			t.Errorf("show (note) out = %q; want %q", out.String(), note)
+		}
+	}
+}
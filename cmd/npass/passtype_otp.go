@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+)
+
+// passTOTP is a time-based one-time password key.
+type passTOTP struct{ otpKey }
+
+func (p *passTOTP) readPass(ctx context.Context, a *app, name string) error {
+	s, err := a.pin.NewPass(ctx, fmt.Sprintf("Enter TOTP secret or otpauth:// URI for %q:", name))
+	if err != nil {
+		return err
+	}
+
+	k, err := parseOTPKey("totp", s)
+	if err != nil {
+		return err
+	}
+
+	p.otpKey = k
+	return nil
+}
+
+func (p *passTOTP) printPass() (string, error) {
+	code, left := p.totp(timeNow())
+	return fmt.Sprintf("%s (%ds left)", code, left), nil
+}
+
+func (p *passTOTP) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+func (p *passTOTP) UnmarshalText(b []byte) error {
+	k, err := parseOTPKey("totp", string(b))
+	if err != nil {
+		return err
+	}
+
+	p.otpKey = k
+	return nil
+}
+
+// Number of counter values searched when resynchronizing.
+const hotpResyncWindow = 100
+
+// Largest resync window, so that a typo cannot search for hours.
+const hotpMaxResyncWindow = 1000
+
+// passHOTP is a counter-based one-time password key. The counter is stored
+// alongside the key, and advanced every time a code is printed.
+type passHOTP struct {
+	otpKey
+	dirty bool
+}
+
+func (p *passHOTP) readPass(ctx context.Context, a *app, name string) error {
+	s, err := a.pin.NewPass(ctx, fmt.Sprintf("Enter HOTP secret or otpauth:// URI for %q:", name))
+	if err != nil {
+		return err
+	}
+
+	k, err := parseOTPKey("hotp", s)
+	if err != nil {
+		return err
+	}
+
+	p.otpKey = k
+	return nil
+}
+
+func (p *passHOTP) printPass() (string, error) {
+	code := p.code(p.counter)
+	p.counter++
+	p.dirty = true
+	return code, nil
+}
+
+func (p *passHOTP) modified() bool {
+	return p.dirty
+}
+
+// operate supports "resync CODE [WINDOW]", which searches the next WINDOW
+// counter values for CODE and advances the counter past it.
+func (p *passHOTP) operate(op string, args []string) (string, error) {
+	if op != "resync" || len(args) < 1 || len(args) > 2 {
+		return "", errInvalidOperation
+	}
+
+	window := uint64(hotpResyncWindow)
+	if len(args) == 2 {
+		var err error
+		window, err = strconv.ParseUint(args[1], 10, 64)
+		if err != nil {
+			return "", errInvalidOperation
+		}
+		if window > hotpMaxResyncWindow {
+			return "", fmt.Errorf("%w: resync window over %d", errInvalidOperation, hotpMaxResyncWindow)
+		}
+	}
+
+	for i := uint64(0); i < window; i++ {
+		if p.code(p.counter+i) == args[0] {
+			p.counter += i + 1
+			p.dirty = true
+			return fmt.Sprintf("resynchronized counter to %d", p.counter), nil
+		}
+	}
+
+	return "", fmt.Errorf("code not found within %d counter values", window)
+}
+
+func (p *passHOTP) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+func (p *passHOTP) UnmarshalText(b []byte) error {
+	k, err := parseOTPKey("hotp", string(b))
+	if err != nil {
+		return err
+	}
+
+	p.otpKey = k
+	return nil
+}
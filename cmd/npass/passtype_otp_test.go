@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func testTimeNow(t *testing.T, now time.Time) {
+	timeNow = func() time.Time { return now }
+	t.Cleanup(func() { timeNow = time.Now })
+}
+
+func TestPassTOTPRead(t *testing.T) {
+	pin := &testPinentry{pass: "otpauth://totp/label?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&digits=8"}
+	a, _ := testNewApp(t, pin)
+	p := new(passTOTP)
+
+	err := p.readPass(context.Background(), a, "testing")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if string(p.secret) != "12345678901234567890" || p.digits != 8 {
+		t.Errorf("readPass returned %#v", p.otpKey)
+	}
+}
+
+func TestPassTOTPReadFail(t *testing.T) {
+	pin := &testPinentry{pass: "invalid!"}
+	a, _ := testNewApp(t, pin)
+	p := new(passTOTP)
+
+	err := p.readPass(context.Background(), a, "testing")
+	if !errors.Is(err, errOTP) {
+		t.Errorf("readPass err = %v; want %v", err, errOTP)
+	}
+}
+
+func TestPassTOTPPrint(t *testing.T) {
+	testTimeNow(t, time.Unix(1111111109, 0))
+
+	p := new(passTOTP)
+	err := p.UnmarshalText([]byte("GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got, err := p.printPass()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "081804 (1s left)"; got != want {
+		t.Errorf("printPass() = %q; want %q", got, want)
+	}
+}
+
+func TestPassTOTPMarshalText(t *testing.T) {
+	p := new(passTOTP)
+	err := p.UnmarshalText([]byte("GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got, err := p.MarshalText()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := "otpauth://totp/?algorithm=SHA1&digits=6&period=30&secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	if string(got) != want {
+		t.Errorf("MarshalText() = %q; want %q", got, want)
+	}
+}
+
+func TestCmdShowTOTP(t *testing.T) {
+	testTimeNow(t, time.Unix(59, 0))
+
+	ctx := context.Background()
+	pin := &testPinentry{pass: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"}
+	app, out := testNewApp(t, pin)
+
+	err := app.run(ctx, []string{"new", "test-1:test-1:totp"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	out.Reset()
+	pin.pass = "pass-1"
+	err = app.run(ctx, []string{"show", "test-1:test-1:totp"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "287082 (1s left)\n"; out.String() != want {
+		t.Errorf("show (totp) out = %q; want %q", out.String(), want)
+	}
+}
+
+func TestPassHOTPPrint(t *testing.T) {
+	p := new(passHOTP)
+	err := p.UnmarshalText([]byte("otpauth://hotp/?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&counter=1"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if p.modified() {
+		t.Errorf("modified() = %t; want %t", true, false)
+	}
+
+	for _, want := range []string{"287082", "359152"} {
+		got, err := p.printPass()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got != want {
+			t.Errorf("printPass() = %q; want %q", got, want)
+		}
+	}
+
+	if !p.modified() {
+		t.Errorf("modified() = %t; want %t", false, true)
+	}
+	if p.counter != 3 {
+		t.Errorf("counter = %d; want %d", p.counter, 3)
+	}
+}
+
+func TestPassHOTPResync(t *testing.T) {
+	p := new(passHOTP)
+	err := p.UnmarshalText([]byte("GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got, err := p.operate("resync", []string{"162583"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "resynchronized counter to 8"; got != want {
+		t.Errorf("operate() = %q; want %q", got, want)
+	}
+	if !p.modified() {
+		t.Errorf("modified() = %t; want %t", false, true)
+	}
+
+	_, err = p.operate("resync", []string{"755224", "5"})
+	if err == nil {
+		t.Errorf("operate() did not error; want error")
+	}
+
+	for _, args := range [][]string{{}, {"1", "x"}, {"1", "2", "3"}, {"1", "1001"}, {"1", "9999999999"}} {
+		_, err = p.operate("resync", args)
+		if !errors.Is(err, errInvalidOperation) {
+			t.Errorf("operate(%q) err = %v; want %v", args, err, errInvalidOperation)
+		}
+	}
+
+	_, err = p.operate("resync", []string{"x", "1000"})
+	if err == nil || errors.Is(err, errInvalidOperation) {
+		t.Errorf("operate() err = %v; want code not found", err)
+	}
+
+	_, err = p.operate("invalid", nil)
+	if !errors.Is(err, errInvalidOperation) {
+		t.Errorf("operate() err = %v; want %v", err, errInvalidOperation)
+	}
+}
+
+func TestCmdShowHOTP(t *testing.T) {
+	ctx := context.Background()
+	pin := &testPinentry{pass: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"}
+	app, out := testNewApp(t, pin)
+
+	err := app.run(ctx, []string{"new", "test-1:test-1:hotp"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	pin.pass = "pass-1"
+	for _, want := range []string{"755224\n", "287082\n"} {
+		out.Reset()
+		err = app.run(ctx, []string{"show", "test-1:test-1:hotp"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if out.String() != want {
+			t.Errorf("show (hotp) out = %q; want %q", out.String(), want)
+		}
+	}
+
+	out.Reset()
+	err = app.run(ctx, []string{"show", "test-1:test-1:hotp", "resync", "399871"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "resynchronized counter to 9\n"; out.String() != want {
+		t.Errorf("show (hotp) out = %q; want %q", out.String(), want)
+	}
+
+	out.Reset()
+	err = app.run(ctx, []string{"show", "test-1:test-1:hotp"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "520489\n"; out.String() != want {
+		t.Errorf("show (hotp) out = %q; want %q", out.String(), want)
+	}
+}
+
+func TestCmdShowOperationFail(t *testing.T) {
+	ctx := context.Background()
+	app, _ := testNewApp(t, &testPinentry{pass: "pass-1"})
+
+	err := app.run(ctx, []string{"show", "test-1:test-1", "resync"})
+	if !errors.Is(err, errUsage) {
+		t.Errorf("show err = %v; want %v", err, errUsage)
+	}
+
+	err = app.run(ctx, []string{"show", "test-1:test-1:pass", "resync"})
+	if want := fmt.Errorf("pass type %q does not support operations", "pass"); !reflect.DeepEqual(err, want) {
+		t.Errorf("show err = %v; want %v", err, want)
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+const recoveryVersion = 1
+
+var (
+	errRecoveryCodes     = errors.New("invalid recovery codes")
+	errNoRecoveryCodes   = errors.New("no recovery codes")
+	errRecoveryCodesUsed = errors.New("all recovery codes have been used")
+)
+
+// passRecoveryCodes is a list of single-use recovery codes. Codes are handed
+// out in order by the "use" operation and marked as consumed.
+//
+// Since show seals the consumed code back within the transaction it was
+// read in, concurrent uses cannot return the same code: the store refuses
+// to commit a write based on a stale read.
+type passRecoveryCodes struct {
+	codes []recoveryCode
+	dirty bool
+}
+
+type recoveryCode struct {
+	Code string `json:"code"`
+	Used bool   `json:"used,omitempty"`
+}
+
+type recoveryRecord struct {
+	Version int            `json:"version"`
+	Codes   []recoveryCode `json:"codes"`
+}
+
+// readPass reads whitespace-separated codes from a.r, or if it is a
+// terminal, from an editor. All codes read are unused.
+func (p *passRecoveryCodes) readPass(ctx context.Context, a *app, name string) error {
+	var (
+		b   []byte
+		err error
+	)
+	if isTerminal(a.r) {
+		b, err = a.editText(ctx, nil)
+	} else {
+		b, err = ioutil.ReadAll(a.r)
+	}
+	if err != nil {
+		return err
+	}
+
+	fields := strings.Fields(string(b))
+	if len(fields) == 0 {
+		return errNoRecoveryCodes
+	}
+
+	codes := make([]recoveryCode, 0, len(fields))
+	for _, c := range fields {
+		codes = append(codes, recoveryCode{Code: c})
+	}
+
+	p.codes = codes
+	return nil
+}
+
+func (p *passRecoveryCodes) printPass() (string, error) {
+	return fmt.Sprintf("%d of %d recovery codes left", p.left(), len(p.codes)), nil
+}
+
+func (p *passRecoveryCodes) modified() bool {
+	return p.dirty
+}
+
+// operate supports "use", which prints the next unused code and marks it
+// as consumed.
+func (p *passRecoveryCodes) operate(op string, args []string) (string, error) {
+	if op != "use" || len(args) != 0 {
+		return "", errInvalidOperation
+	}
+
+	for i := range p.codes {
+		if !p.codes[i].Used {
+			p.codes[i].Used = true
+			p.dirty = true
+			return p.codes[i].Code, nil
+		}
+	}
+
+	return "", errRecoveryCodesUsed
+}
+
+func (p *passRecoveryCodes) left() int {
+	var n int
+	for _, c := range p.codes {
+		if !c.Used {
+			n++
+		}
+	}
+	return n
+}
+
+func (p *passRecoveryCodes) MarshalText() ([]byte, error) {
+	return json.Marshal(recoveryRecord{
+		Version: recoveryVersion,
+		Codes:   p.codes,
+	})
+}
+
+func (p *passRecoveryCodes) UnmarshalText(b []byte) error {
+	var rec recoveryRecord
+	if err := json.Unmarshal(b, &rec); err != nil {
+		return fmt.Errorf("%w: %v", errRecoveryCodes, err)
+	}
+	if rec.Version != recoveryVersion {
+		return fmt.Errorf("%w: unsupported version %d", errRecoveryCodes, rec.Version)
+	}
+
+	p.codes = rec.Codes
+	return nil
+}
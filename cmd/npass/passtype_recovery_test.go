@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPassRecoveryCodesRead(t *testing.T) {
+	a, _ := testNewApp(t, &testPinentry{})
+	a.r = strings.NewReader("aaaa-1111 bbbb-2222\ncccc-3333\n")
+	p := new(passRecoveryCodes)
+
+	err := p.readPass(context.Background(), a, "testing")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []recoveryCode{{Code: "aaaa-1111"}, {Code: "bbbb-2222"}, {Code: "cccc-3333"}}
+	if !reflect.DeepEqual(p.codes, want) {
+		t.Errorf("readPass returned %v; want %v", p.codes, want)
+	}
+}
+
+func TestPassRecoveryCodesReadEmpty(t *testing.T) {
+	a, _ := testNewApp(t, &testPinentry{})
+	a.r = strings.NewReader(" \n")
+	p := new(passRecoveryCodes)
+
+	err := p.readPass(context.Background(), a, "testing")
+	if !errors.Is(err, errNoRecoveryCodes) {
+		t.Errorf("readPass err = %v; want %v", err, errNoRecoveryCodes)
+	}
+}
+
+func TestPassRecoveryCodesMarshalText(t *testing.T) {
+	p := &passRecoveryCodes{codes: []recoveryCode{{Code: "a", Used: true}, {Code: "b"}}}
+
+	b, err := p.MarshalText()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var p1 passRecoveryCodes
+	if err := p1.UnmarshalText(b); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(p1.codes, p.codes) {
+		t.Errorf("UnmarshalText returned %v; want %v", p1.codes, p.codes)
+	}
+
+	err = p1.UnmarshalText([]byte(`{"version":2}`))
+	if !errors.Is(err, errRecoveryCodes) {
+		t.Errorf("UnmarshalText err = %v; want %v", err, errRecoveryCodes)
+	}
+}
+
+func TestCmdShowRecoveryCodes(t *testing.T) {
+	ctx := context.Background()
+	app, out := testNewApp(t, &testPinentry{pass: "pass-1"})
+
+	app.r = strings.NewReader("code-1\ncode-2\n")
+	err := app.run(ctx, []string{"new", "test-1:github:recovery-codes"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		args []string
+		out  string
+	}{
+		{[]string{"show", "test-1:github:recovery-codes"}, "2 of 2 recovery codes left\n"},
+		{[]string{"show", "test-1:github:recovery-codes", "use"}, "code-1\n"},
+		{[]string{"show", "test-1:github:recovery-codes"}, "1 of 2 recovery codes left\n"},
+		{[]string{"show", "test-1:github:recovery-codes", "use"}, "code-2\n"},
+		{[]string{"show", "test-1:github:recovery-codes"}, "0 of 2 recovery codes left\n"},
+	}
+
+	for _, tt := range tests {
+		out.Reset()
+		err := app.run(ctx, tt.args)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if out.String() != tt.out {
+			t.Errorf("%v out = %q; want %q", tt.args, out.String(), tt.out)
+		}
+	}
+
+	out.Reset()
+	err = app.run(ctx, []string{"show", "test-1:github:recovery-codes", "use"})
+	if !errors.Is(err, errRecoveryCodesUsed) {
+		t.Errorf("show use err = %v; want %v", err, errRecoveryCodesUsed)
+	}
+	if out.Len() != 0 {
+		t.Errorf("show use out = %q; want empty", out.String())
+	}
+}
+
+// testPinentryHook runs hook on AskPass, before checking the password.
+type testPinentryHook struct {
+	testPinentry
+	hook func()
+}
+
+func (tp testPinentryHook) AskPass(ctx context.Context, desc string, f func(string) bool) (string, error) {
+	tp.hook()
+	return tp.testPinentry.AskPass(ctx, desc, f)
+}
+
+func TestCmdShowRecoveryCodesConcurrent(t *testing.T) {
+	ctx := context.Background()
+	testKDF(t)
+
+	dir, err := ioutil.TempDir("", "npass-test-")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	// Two apps on one database, as two processes would be, which do not
+	// share a cache
+	oldArgs := defaultStoreArgs
+	defaultStoreArgs = map[string]string{"_foreign_keys": "true", "_busy_timeout": "0", "cache": "private"}
+	t.Cleanup(func() { defaultStoreArgs = oldArgs })
+
+	var apps [2]*app
+	var outs [2]*bytes.Buffer
+	for i := range apps {
+		st, err := newStore(filepath.Join(dir, "npass.db"), nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		t.Cleanup(func() { st.Close() })
+		if i == 0 {
+			testStoreFixture(t, st)
+			if _, err := st.migrate(ctx, ""); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+		outs[i] = &bytes.Buffer{}
+		apps[i] = &app{w: outs[i], st: st, pin: &testPinentry{pass: "pass-1"}}
+	}
+
+	apps[0].r = strings.NewReader("code-1\ncode-2\n")
+	if err := apps[0].run(ctx, []string{"new", "test-1:github:recovery-codes"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	outs[0].Reset()
+
+	// The first use holds its transaction open while the second runs
+	args := []string{"show", "test-1:github:recovery-codes", "use"}
+	var errs [2]error
+	apps[0].pin = testPinentryHook{
+		testPinentry: testPinentry{pass: "pass-1"},
+		hook:         func() { errs[1] = apps[1].run(ctx, args) },
+	}
+	errs[0] = apps[0].run(ctx, args)
+
+	var used int
+	for i := range apps {
+		if errs[i] == nil {
+			used++
+			if want := "code-1\n"; outs[i].String() != want {
+				t.Errorf("app %d: show use out = %q; want %q", i, outs[i].String(), want)
+			}
+		} else if outs[i].Len() != 0 {
+			t.Errorf("app %d: show use out = %q; want empty", i, outs[i].String())
+		}
+	}
+	if used != 1 {
+		t.Errorf("show use succeeded %d times, errs = %v; want once", used, errs)
+	}
+
+	// Exactly one code was consumed
+	apps[0].pin = &testPinentry{pass: "pass-1"}
+	outs[0].Reset()
+	if err := apps[0].run(ctx, []string{"show", "test-1:github:recovery-codes"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "1 of 2 recovery codes left\n"; outs[0].String() != want {
+		t.Errorf("show out = %q; want %q", outs[0].String(), want)
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+var errSSHKey = errors.New("invalid ssh key")
+
+// passSSHKey is an SSH private key, stored in PEM form. Printing it shows
+// the public key in authorized_keys format.
+type passSSHKey struct {
+	pem    []byte
+	signer ssh.Signer
+}
+
+// readPass reads a PEM or OpenSSH private key from a.r. Passphrase-protected
+// keys are decrypted before they are stored.
+func (p *passSSHKey) readPass(ctx context.Context, a *app, name string) error {
+	b, err := ioutil.ReadAll(a.r)
+	if err != nil {
+		return err
+	}
+
+	raw, err := ssh.ParseRawPrivateKey(b)
+	var pme *ssh.PassphraseMissingError
+	if errors.As(err, &pme) {
+		_, err = a.pin.AskPass(ctx, fmt.Sprintf("Enter passphrase for ssh key %q:", name),
+			func(pass string) bool {
+				dec, err := ssh.ParseRawPrivateKeyWithPassphrase(b, []byte(pass))
+				if err != nil {
+					return false
+				}
+
+				raw = dec
+				return true
+			},
+		)
+		if err != nil {
+			return err
+		}
+
+		b, err = marshalSSHKey(raw)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %v", errSSHKey, err)
+	}
+
+	return p.UnmarshalText(b)
+}
+
+// generatePass generates a new ed25519 key.
+func (p *passSSHKey) generatePass() error {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return err
+	}
+
+	b, err := marshalSSHKey(priv)
+	if err != nil {
+		return err
+	}
+	return p.UnmarshalText(b)
+}
+
+func (p *passSSHKey) printPass() (string, error) {
+	return strings.TrimSuffix(string(ssh.MarshalAuthorizedKey(p.signer.PublicKey())), "\n"), nil
+}
+
+// operate supports "private", which prints the private key for export.
+func (p *passSSHKey) operate(op string, args []string) (string, error) {
+	if op != "private" || len(args) != 0 {
+		return "", errInvalidOperation
+	}
+	return string(p.pem), nil
+}
+
+func (p *passSSHKey) MarshalText() ([]byte, error) {
+	return p.pem, nil
+}
+
+func (p *passSSHKey) UnmarshalText(b []byte) error {
+	b = bytes.TrimSpace(b)
+
+	signer, err := ssh.ParsePrivateKey(b)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errSSHKey, err)
+	}
+
+	p.pem = append(b, '\n')
+	p.signer = signer
+	return nil
+}
+
+// marshalSSHKey encodes a private key as an unencrypted PKCS #8 PEM block.
+func marshalSSHKey(key crypto.PrivateKey) ([]byte, error) {
+	// The ssh package returns ed25519 keys by reference
+	if k, ok := key.(*ed25519.PrivateKey); ok {
+		key = *k
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+type dbKey struct {
+	id           int64
+	name         string
+	pub, priv    string
+	privateIndex bool
+	// Minimum padded size of passes, see pad.
+	padding int
+
+	// The decrypted private key and its password, once unlocked.
+	unlocked *[32]byte
+	password string
+}
+
+// queryKey looks up a key by name, ignoring trashed keys.
+func queryKey(tx *sql.Tx, key string) (dbKey, error) {
+	k := dbKey{name: key}
+
+	query := `SELECT id, public, private, private_index, padding FROM keys WHERE name = ? AND trashed IS NULL LIMIT 1`
+	err := tx.QueryRow(query, key).Scan(&k.id, &k.pub, &k.priv, &k.privateIndex, &k.padding)
+	if errors.Is(err, sql.ErrNoRows) {
+		return dbKey{}, fmt.Errorf("non-existent key %q", key)
+	}
+	if err != nil {
+		return dbKey{}, err
+	}
+
+	return k, nil
+}
+
+type dbPass struct {
+	id        int64
+	name, typ string
+	data      string
+
+	// The values stored in the name and type columns, which differ from
+	// name and typ under a private index. Passes are sealed to these.
+	idxName, idxType string
+	sealedName       sql.NullString
+}
+
+// queryPass looks up a pass by name and type under the key k, ignoring
+// trashed passes. Private indexes must have been opened.
+func queryPass(tx *sql.Tx, k dbKey, name, typ string) (dbPass, error) {
+	p := dbPass{name: name, typ: typ}
+
+	var err error
+	p.idxName, p.idxType, err = k.passIndex(name, typ)
+	if err != nil {
+		return dbPass{}, err
+	}
+
+	query := `SELECT id, data FROM pass WHERE key_id = ? AND name = ? AND type = ? AND trashed IS NULL LIMIT 1`
+	err = tx.QueryRow(query, k.id, p.idxName, p.idxType).Scan(&p.id, &p.data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return dbPass{}, fmt.Errorf("non-existent pass %q",
+			fmt.Sprintf("%s:%s:%s", k.name, name, typ))
+	}
+	if err != nil {
+		return dbPass{}, err
+	}
+
+	return p, nil
+}
+
+// queryPasses returns the passes under k, trashed or not, in the order of
+// their stored names. Passes under a private index are named once the key is
+// unlocked, see (dbKey).passName.
+func queryPasses(tx *sql.Tx, k dbKey, cond string, args ...interface{}) ([]dbPass, error) {
+	query := `SELECT id, name, type, sealed_name, data FROM pass WHERE key_id = ?`
+	if cond != "" {
+		query += ` AND ` + cond
+	}
+	query += ` ORDER BY name, type`
+
+	rows, err := tx.Query(query, append([]interface{}{k.id}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var pass []dbPass
+	for rows.Next() {
+		var p dbPass
+		if err := rows.Scan(&p.id, &p.idxName, &p.idxType, &p.sealedName, &p.data); err != nil {
+			return nil, err
+		}
+		if !k.privateIndex {
+			p.name, p.typ = p.idxName, p.idxType
+		}
+		pass = append(pass, p)
+	}
+	return pass, rows.Err()
+}
+
+// namePasses decodes the names of passes returned by queryPasses, sorting
+// them by name. Private indexes must have been opened.
+func namePasses(k dbKey, pass []dbPass) error {
+	if !k.privateIndex {
+		return nil
+	}
+
+	for i, p := range pass {
+		var err error
+		pass[i].name, pass[i].typ, err = k.passName(p.idxName, p.idxType, p.sealedName)
+		if err != nil {
+			return err
+		}
+	}
+	sort.Slice(pass, func(i, j int) bool {
+		if pass[i].name != pass[j].name {
+			return pass[i].name < pass[j].name
+		}
+		return pass[i].typ < pass[j].typ
+	})
+	return nil
+}
+
+// unlockPass asks for the password of k and decrypts p into a new pass of
+// the appropriate type. Rows still in a legacy layout are upgraded in place.
+func (a *app) unlockPass(ctx context.Context, tx *sql.Tx, k dbKey, p dbPass) (passType, error) {
+	pass, err := newPass(p.typ)
+	if err != nil {
+		return nil, err
+	}
+
+	pub, err := decodePubKey(k.pub)
+	if err != nil {
+		return nil, err
+	}
+
+	priv, err := a.unlock(ctx, &k)
+	if err != nil {
+		return nil, err
+	}
+
+	dec, err := openPass(pub, priv, k.name, p.idxName, p.idxType, p.data)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := upgradeKey(tx, k, priv, k.password); err != nil {
+		return nil, err
+	}
+	if _, err := upgradePass(tx, k, p, dec); err != nil {
+		return nil, err
+	}
+
+	if err := pass.UnmarshalText(dec); err != nil {
+		return nil, err
+	}
+	return pass, nil
+}
+
+// updatePass reseals pass to k and writes it back to the row p, recording
+// the modification.
+func updatePass(tx *sql.Tx, k dbKey, p dbPass, pass passType) error {
+	enc, err := resealPass(k, p, pass)
+	if err != nil {
+		return err
+	}
+
+	query := `UPDATE pass SET data = ?, updated = ? WHERE id = ?`
+	_, err = tx.Exec(query, enc, timeNow().Unix(), p.id)
+	return err
+}
+
+// updatePassState is updatePass for changes to the state of a passStateful
+// alone, such as a counter, which are not modifications.
+func updatePassState(tx *sql.Tx, k dbKey, p dbPass, pass passType) error {
+	enc, err := resealPass(k, p, pass)
+	if err != nil {
+		return err
+	}
+
+	query := `UPDATE pass SET data = ? WHERE id = ?`
+	_, err = tx.Exec(query, enc, p.id)
+	return err
+}
+
+func resealPass(k dbKey, p dbPass, pass passType) (string, error) {
+	data, err := pass.MarshalText()
+	if err != nil {
+		return "", err
+	}
+
+	pub, err := decodePubKey(k.pub)
+	if err != nil {
+		return "", err
+	}
+
+	return sealPass(pub, k.name, p.idxName, p.idxType, data, k.padding)
+}
+
+// upgradeKey reseals the private key of k with the current algorithm,
+// keeping its KDF parameters, if it is still in a legacy layout. It reports
+// whether it did.
+func upgradeKey(tx *sql.Tx, k dbKey, priv *[32]byte, password string) (bool, error) {
+	if isCurrent(k.priv, algKey) {
+		return false, nil
+	}
+
+	params, err := privKeyParams(k.priv)
+	if err != nil {
+		return false, err
+	}
+	privEnc, err := sealPrivKey(password, priv, params)
+	if err != nil {
+		return false, err
+	}
+
+	query := `UPDATE keys SET private = ? WHERE id = ?`
+	_, err = tx.Exec(query, privEnc, k.id)
+	return err == nil, err
+}
+
+// upgradePass reseals the decrypted data of p with the current algorithm and
+// the padding of k, if it is still in a legacy layout. It reports whether it
+// did.
+func upgradePass(tx *sql.Tx, k dbKey, p dbPass, dec []byte) (bool, error) {
+	if k.isCurrentPass(p.data) {
+		return false, nil
+	}
+	err := resealPassData(tx, k, p, dec)
+	return err == nil, err
+}
+
+// resealPassData reseals the decrypted data of p with the current algorithm
+// and the padding of k.
+func resealPassData(tx *sql.Tx, k dbKey, p dbPass, dec []byte) error {
+	pub, err := decodePubKey(k.pub)
+	if err != nil {
+		return err
+	}
+	enc, err := sealPass(pub, k.name, p.idxName, p.idxType, dec, k.padding)
+	if err != nil {
+		return err
+	}
+
+	query := `UPDATE pass SET data = ? WHERE id = ?`
+	_, err = tx.Exec(query, enc, p.id)
+	return err
+}
+
+// accessPass records the access of the pass p, and of its key k.
+func accessPass(tx *sql.Tx, k dbKey, p dbPass) error {
+	now := timeNow().Unix()
+
+	queryKey := `UPDATE keys SET accessed = ? WHERE id = ?`
+	if _, err := tx.Exec(queryKey, now, k.id); err != nil {
+		return err
+	}
+
+	queryPass := `UPDATE pass SET accessed = ? WHERE id = ?`
+	_, err := tx.Exec(queryPass, now, p.id)
+	return err
+}
@@ -3,6 +3,8 @@ package main
 import (
 	"context"
 	"errors"
+	"flag"
+	"io/ioutil"
 )
 
 var errUsage = errors.New("incorrect usage")
@@ -35,3 +37,11 @@ func (rm runMap) run(ctx context.Context, args []string) error {
 
 	return run.run(ctx, args[1:])
 }
+
+// newFlagSet returns a flag set for subcommand options. Parse errors should be
+// reported as errUsage.
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	return fs
+}
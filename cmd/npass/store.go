@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"net/url"
+	"strconv"
 
 	_ "github.com/mattn/go-sqlite3"
 )
@@ -30,11 +31,93 @@ CREATE TABLE meta (
 	key	TEXT	PRIMARY KEY NOT NULL,
 	value	TEXT	NOT NULL
 );
-INSERT INTO meta (key, value) VALUES('version', ?);
+INSERT INTO meta (key, value) VALUES('version', '1');
 `
-	schemaVersion = "1"
 )
 
+// A migration upgrades the schema by one version, from len(migrations[:i])+1
+// for migrations[i]. It is run in its own transaction, after which the
+// version is updated.
+type migration func(ctx context.Context, tx *sql.Tx) error
+
+// Migrations in order, starting from the initial schema. New migrations are
+// appended; existing ones must never change.
+var migrations = []migration{
+	// 2: creation, modification and access times, in Unix seconds
+	func(ctx context.Context, tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, `
+ALTER TABLE keys ADD COLUMN created INTEGER;
+ALTER TABLE keys ADD COLUMN updated INTEGER;
+ALTER TABLE keys ADD COLUMN accessed INTEGER;
+ALTER TABLE pass ADD COLUMN created INTEGER;
+ALTER TABLE pass ADD COLUMN updated INTEGER;
+ALTER TABLE pass ADD COLUMN accessed INTEGER;
+`)
+		return err
+	},
+	// 3: tags of passes
+	func(ctx context.Context, tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, `
+CREATE TABLE tags (
+	pass_id	INTEGER	NOT NULL REFERENCES pass(id) ON DELETE CASCADE,
+	tag	TEXT	NOT NULL,
+	PRIMARY KEY	(pass_id, tag)
+);
+CREATE INDEX tags_tag ON tags (tag);
+`)
+		return err
+	},
+	// 4: previous versions of passes, sealed to the key they were sealed to
+	// at the time
+	func(ctx context.Context, tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, `
+CREATE TABLE history (
+	id	INTEGER	PRIMARY KEY NOT NULL,
+	pass_id	INTEGER	NOT NULL REFERENCES pass(id) ON DELETE CASCADE,
+	key_name	TEXT	NOT NULL,
+	key_public	TEXT	NOT NULL,
+	name	TEXT	NOT NULL,
+	type	TEXT	NOT NULL,
+	data	TEXT	NOT NULL,
+	created	INTEGER	NOT NULL
+);
+CREATE INDEX history_pass_id ON history (pass_id);
+`)
+		return err
+	},
+	// 5: trash, holding removed keys and passes until it is emptied
+	func(ctx context.Context, tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, `
+ALTER TABLE keys ADD COLUMN trashed INTEGER;
+ALTER TABLE pass ADD COLUMN trashed INTEGER;
+`)
+		return err
+	},
+	// 6: private index, storing pass names and types as HMACs along with a
+	// sealed display name
+	func(ctx context.Context, tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, `
+ALTER TABLE keys ADD COLUMN private_index INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE pass ADD COLUMN sealed_name TEXT;
+`)
+		return err
+	},
+	// 7: per-key padding of sealed passes. Existing keys pad to 64 bytes
+	// as new keys do, and their unpadded passes are resealed as they are
+	// unlocked.
+	func(ctx context.Context, tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, `
+ALTER TABLE keys ADD COLUMN padding INTEGER NOT NULL DEFAULT 64;
+`)
+		return err
+	},
+}
+
+// schemaVersion returns the current schema version.
+func schemaVersion() int {
+	return len(migrations) + 1
+}
+
 type store struct{ *sql.DB }
 
 var defaultStoreArgs = map[string]string{
@@ -64,33 +147,112 @@ func newStore(name string, args map[string]string) (store, error) {
 	return store{db}, nil
 }
 
+// checkSchema reports whether the schema is initialized and up to date.
 func (st *store) checkSchema(ctx context.Context) (bool, error) {
+	version, err := st.version(ctx)
+	if err != nil {
+		return false, err
+	}
+	return version == schemaVersion(), nil
+}
+
+// version returns the schema version, or zero if not initialized.
+func (st *store) version(ctx context.Context) (int, error) {
 	queryExists := `SELECT EXISTS(SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = 'meta')`
 
 	var exists bool
-	err := st.QueryRow(queryExists).Scan(&exists)
+	err := st.QueryRowContext(ctx, queryExists).Scan(&exists)
 	if err != nil {
-		return false, err
+		return 0, err
 	}
 	if !exists {
-		return false, nil
+		return 0, nil
 	}
 
 	queryVersion := `SELECT value FROM meta WHERE key = 'version'`
 
 	var version string
-	err = st.QueryRow(queryVersion).Scan(&version)
-	if err != nil && !errors.Is(err, sql.ErrNoRows) {
-		return false, err
-	}
+	err = st.QueryRowContext(ctx, queryVersion).Scan(&version)
 	if errors.Is(err, sql.ErrNoRows) {
-		return false, nil
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
 	}
 
-	return version == schemaVersion, nil
+	v, err := strconv.Atoi(version)
+	if err != nil || v < 1 {
+		return 0, fmt.Errorf("invalid schema version %q", version)
+	}
+	return v, nil
 }
 
+// initSchema initializes an empty database with the latest schema.
 func (st *store) initSchema(ctx context.Context) error {
-	_, err := st.ExecContext(ctx, schema, schemaVersion)
+	if _, err := st.ExecContext(ctx, schema); err != nil {
+		return err
+	}
+
+	_, err := st.migrate(ctx, "")
 	return err
 }
+
+// migrate upgrades the schema to the latest version, returning the version it
+// started from. If any migrations are needed and backup is not empty, the
+// database is first copied to backup, which must not exist.
+func (st *store) migrate(ctx context.Context, backup string) (int, error) {
+	from, err := st.version(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if from == 0 {
+		return 0, fmt.Errorf("database is not initialized")
+	}
+	if from > schemaVersion() {
+		return from, fmt.Errorf("database schema version %d is newer than supported version %d, upgrade npass",
+			from, schemaVersion())
+	}
+	if from == schemaVersion() {
+		return from, nil
+	}
+
+	if backup != "" {
+		if _, err := st.ExecContext(ctx, `VACUUM INTO ?`, backup); err != nil {
+			return from, fmt.Errorf("could not back up db: %w", err)
+		}
+	}
+
+	for v := from; v < schemaVersion(); v++ {
+		if err := st.migrateOne(ctx, v); err != nil {
+			return from, fmt.Errorf("could not migrate db from version %d: %w", v, err)
+		}
+	}
+
+	return from, nil
+}
+
+func (st *store) migrateOne(ctx context.Context, from int) error {
+	tx, err := st.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := migrations[from-1](ctx, tx); err != nil {
+		return err
+	}
+
+	queryVersion := `UPDATE meta SET value = ? WHERE key = 'version' AND value = ?`
+	res, err := tx.Exec(queryVersion, strconv.Itoa(from+1), strconv.Itoa(from))
+	if err != nil {
+		return err
+	}
+	// Guard against concurrent migrations
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n != 1 {
+		return fmt.Errorf("schema version changed during migration")
+	}
+
+	return tx.Commit()
+}
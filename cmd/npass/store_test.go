@@ -2,6 +2,13 @@ package main
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
 )
 
@@ -28,7 +35,17 @@ func testStore(t *testing.T) store {
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
+	// The in-memory database lives as long as a connection to it, and
+	// database/sql drops connections, such as those of interrupted
+	// transactions, so one is held for the whole test.
+	conn, err := st.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
 	t.Cleanup(func() {
+		if err := conn.Close(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
 		if err := st.Close(); err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -79,3 +96,152 @@ func TestStoreInitSchema(t *testing.T) {
 		t.Errorf("checkSchema() = %t; want %t", ok, false)
 	}
 }
+
+func testMigrations(t *testing.T, ms ...migration) {
+	old := migrations
+	migrations = append(migrations[:len(migrations):len(migrations)], ms...)
+	t.Cleanup(func() { migrations = old })
+}
+
+func testStoreFixture(t *testing.T, st store) {
+	schema, err := ioutil.ReadFile("testdata/test_schema.sql")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := st.Exec(string(schema)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestStoreMigrate(t *testing.T) {
+	ctx := context.Background()
+	st := testStore(t)
+	testStoreFixture(t, st)
+
+	// The fixture is at the initial version
+	from := 1
+	testMigrations(t,
+		func(ctx context.Context, tx *sql.Tx) error {
+			_, err := tx.Exec(`ALTER TABLE pass ADD COLUMN note TEXT NOT NULL DEFAULT ''`)
+			return err
+		},
+		func(ctx context.Context, tx *sql.Tx) error {
+			_, err := tx.Exec(`UPDATE pass SET note = name`)
+			return err
+		},
+	)
+
+	dir, err := ioutil.TempDir("", "npass-test-")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	backup := filepath.Join(dir, "backup.db")
+
+	v, err := st.migrate(ctx, backup)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if v != from {
+		t.Errorf("migrate() = %d; want %d", v, from)
+	}
+
+	v, err = st.version(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if v != schemaVersion() {
+		t.Errorf("version() = %d; want %d", v, schemaVersion())
+	}
+
+	var note string
+	err = st.QueryRow(`SELECT note FROM pass WHERE id = 1`).Scan(&note)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if note != "test-1" {
+		t.Errorf("migrated note = %q; want %q", note, "test-1")
+	}
+
+	// The backup holds the database as it was before migrating
+	bst, err := newStore(backup, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer bst.Close()
+	v, err = bst.version(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if v != from {
+		t.Errorf("backup version() = %d; want %d", v, from)
+	}
+
+	// Migrating again is a no-op
+	v, err = st.migrate(ctx, backup)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if v != schemaVersion() {
+		t.Errorf("migrate() = %d; want %d", v, schemaVersion())
+	}
+}
+
+func TestStoreMigrateFail(t *testing.T) {
+	ctx := context.Background()
+	st := testStore(t)
+	testStoreFixture(t, st)
+
+	errMigrate := errors.New("migration error (testing)")
+	testMigrations(t,
+		func(ctx context.Context, tx *sql.Tx) error {
+			_, err := tx.Exec(`CREATE TABLE test_1 (id INTEGER PRIMARY KEY)`)
+			return err
+		},
+		func(ctx context.Context, tx *sql.Tx) error {
+			if _, err := tx.Exec(`CREATE TABLE test_2 (id INTEGER PRIMARY KEY)`); err != nil {
+				return err
+			}
+			return errMigrate
+		},
+	)
+
+	_, err := st.migrate(ctx, "")
+	if !errors.Is(err, errMigrate) {
+		t.Errorf("migrate() err = %v; want %v", err, errMigrate)
+	}
+
+	// Completed migrations are kept, the failed one is rolled back
+	v, err := st.version(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if v != schemaVersion()-1 {
+		t.Errorf("version() = %d; want %d", v, schemaVersion()-1)
+	}
+
+	var exists bool
+	err = st.QueryRow(`SELECT EXISTS(SELECT 1 FROM sqlite_master WHERE name = 'test_2')`).Scan(&exists)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if exists {
+		t.Errorf("failed migration was not rolled back")
+	}
+}
+
+func TestStoreMigrateNewer(t *testing.T) {
+	ctx := context.Background()
+	st := testStore(t)
+	testStoreFixture(t, st)
+
+	_, err := st.Exec(`UPDATE meta SET value = ? WHERE key = 'version'`, strconv.Itoa(schemaVersion()+1))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	_, err = st.migrate(ctx, "")
+	if err == nil || !strings.Contains(err.Error(), "newer than supported") {
+		t.Errorf("migrate() err = %v; want newer version error", err)
+	}
+}
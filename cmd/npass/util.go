@@ -2,21 +2,13 @@ package main
 
 import (
 	"errors"
-	"fmt"
 	"strings"
 
 	"golang.org/x/crypto/argon2"
 )
 
-// Whether to speed up KDF (for tests).
-var fastKDF = false
-
-func passKey(pass string, salt []byte) []byte {
-	if fastKDF {
-		fmt.Println("WARNING: running with unsafe parameters")
-		return argon2.IDKey([]byte(pass), salt, 1, 32, 1, 32)
-	}
-	return argon2.IDKey([]byte(pass), salt, 1, 6<<20, 8, 32)
+func passKey(pass string, salt []byte, p kdfParams) []byte {
+	return argon2.IDKey([]byte(pass), salt, p.Time, p.Memory, p.Threads, 32)
 }
 
 // Charsets allowed inside identifiers.
@@ -31,6 +23,7 @@ const (
 	charsetKey  = charsetLower + charsetNumber + "-"
 	charsetName = charsetAlnum + charsetSpecial
 	charsetType = charsetLower + "-"
+	charsetTag  = charsetLower + charsetNumber + "-_."
 )
 
 var errIdentifier = errors.New("invalid pass identifier")
@@ -14,6 +14,7 @@ func TestPassKey(t *testing.T) {
 	got := passKey(
 		"0123456789abcdef",
 		[]byte("0123456789abcdef"),
+		legacyKDF,
 	)
 	// Obtained with argon2-cffi python library
 	want, _ := hex.DecodeString("324fc34ab73bd55a748fbe25dc4c122080fa968c82ac0b19ee67285993fa64eb")
@@ -24,12 +25,10 @@ func TestPassKey(t *testing.T) {
 }
 
 func TestPassKeyFast(t *testing.T) {
-	fastKDF = true
-	defer func() { fastKDF = false }()
-
 	got := passKey(
 		"0123456789abcdef",
 		[]byte("0123456789abcdef"),
+		kdfParams{Time: 1, Memory: 32, Threads: 1},
 	)
 	// Obtained with argon2-cffi python library
 	want, _ := hex.DecodeString("92c2708b3e6e914ce3a440f0e2851318c5c400edb0b2c3689d42a1b60f9bdf51")
@@ -48,7 +47,7 @@ func BenchmarkPassKey(b *testing.B) {
 	pass := "0123456789abcdef"
 	salt := []byte("0123456789abcdef")
 	for i := 0; i < b.N; i++ {
-		passKey(pass, salt)
+		passKey(pass, salt, defaultKDF)
 	}
 }
 